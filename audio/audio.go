@@ -3,7 +3,6 @@ package audio
 import (
 	"context"
 	"fmt"
-	"log"
 
 	texttospeech "cloud.google.com/go/texttospeech/apiv1"
 	"github.com/gorilla/websocket"
@@ -15,29 +14,8 @@ const (
 	googleSearchURL = "https://www.googleapis.com/customsearch/v1"
 )
 
-// Audio conversion
-func HandleUserInteraction(sessionID string, userInput string, wsConn *websocket.Conn) error {
-	var immediateResponse string
-	var err error
-
-	immediateResponse, err = GenerateResponse(userInput)
-	if err != nil {
-		log.Println("Error generating response:", err)
-	}
-
-	audioData, err := ConvertTextToSpeech(immediateResponse)
-	if err != nil {
-		log.Println("Error converting text to speech:", err)
-		return err
-	}
-
-	err = StreamAudio(wsConn, audioData)
-	if err != nil {
-		log.Println("Error streaming audio:", err)
-		return err
-	}
-	return nil
-}
+// GoogleCloudAPIKey authenticates Text-to-Speech requests; main sets it at startup.
+var GoogleCloudAPIKey string
 
 func ConvertTextToSpeech(text string) ([]byte, error) {
 	ctx := context.Background()
@@ -0,0 +1,173 @@
+package bm25
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {}, "by": {},
+	"for": {}, "if": {}, "in": {}, "into": {}, "is": {}, "it": {}, "no": {}, "not": {}, "of": {},
+	"on": {}, "or": {}, "such": {}, "that": {}, "the": {}, "their": {}, "then": {}, "there": {},
+	"these": {}, "they": {}, "this": {}, "to": {}, "was": {}, "will": {}, "with": {}, "what": {},
+	"how": {}, "why": {}, "who": {}, "do": {}, "does": {},
+}
+
+// Tokenize lowercases text, splits it on anything that is not a letter or digit and drops stop words.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if _, stop := stopWords[f]; stop {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// Hit is a scored document ID.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is an in-memory Okapi BM25 inverted index. It is safe for concurrent use.
+type Index struct {
+	K1 float64
+	B  float64
+
+	mu       sync.RWMutex
+	postings map[string]map[string]int // term -> doc ID -> term frequency
+	terms    map[string][]string       // doc ID -> distinct terms, for removal
+	lengths  map[string]int
+	totalLen int
+}
+
+func New() *Index {
+	return &Index{
+		K1:       DefaultK1,
+		B:        DefaultB,
+		postings: make(map[string]map[string]int),
+		terms:    make(map[string][]string),
+		lengths:  make(map[string]int),
+	}
+}
+
+// Add indexes text under id, replacing any previous document with the same id.
+func (ix *Index) Add(id, text string) {
+	tokens := Tokenize(text)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+
+	freqs := make(map[string]int)
+	for _, t := range tokens {
+		freqs[t]++
+	}
+	distinct := make([]string, 0, len(freqs))
+	for t, tf := range freqs {
+		p, ok := ix.postings[t]
+		if !ok {
+			p = make(map[string]int)
+			ix.postings[t] = p
+		}
+		p[id] = tf
+		distinct = append(distinct, t)
+	}
+	ix.terms[id] = distinct
+	ix.lengths[id] = len(tokens)
+	ix.totalLen += len(tokens)
+}
+
+// Remove drops id from the index. Unknown ids are ignored.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	distinct, ok := ix.terms[id]
+	if !ok {
+		return
+	}
+	for _, t := range distinct {
+		delete(ix.postings[t], id)
+		if len(ix.postings[t]) == 0 {
+			delete(ix.postings, t)
+		}
+	}
+	ix.totalLen -= ix.lengths[id]
+	delete(ix.terms, id)
+	delete(ix.lengths, id)
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.lengths)
+}
+
+// Search returns up to k documents ranked by BM25 score. k <= 0 returns every match.
+func (ix *Index) Search(query string, k int) []Hit {
+	tokens := Tokenize(query)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := float64(len(ix.lengths))
+	if n == 0 || len(tokens) == 0 {
+		return nil
+	}
+	avgLen := float64(ix.totalLen) / n
+
+	scores := make(map[string]float64)
+	seen := make(map[string]struct{})
+	for _, t := range tokens {
+		if _, dup := seen[t]; dup {
+			continue
+		}
+		seen[t] = struct{}{}
+
+		p := ix.postings[t]
+		if len(p) == 0 {
+			continue
+		}
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			f := float64(tf)
+			norm := ix.K1 * (1 - ix.B + ix.B*float64(ix.lengths[id])/avgLen)
+			scores[id] += idf * f * (ix.K1 + 1) / (f + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if k > 0 && len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
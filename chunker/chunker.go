@@ -0,0 +1,179 @@
+package chunker
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Splitter cuts text into chunks ready for embedding.
+type Splitter interface {
+	Split(ctx context.Context, text string) ([]string, error)
+}
+
+// Chunk is a piece of the input. Start and End are byte offsets into the original
+// text, so Text == text[Start:End].
+type Chunk struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Chunker splits text into chunks of at most MaxTokens tokens. It prefers to cut at
+// paragraph breaks, then sentence ends, then whitespace, and only splits inside a word
+// when a single word is longer than MaxTokens. Consecutive chunks share up to Overlap
+// tokens of whole units so context that straddles a cut appears in both.
+//
+// Every non-whitespace character of the input ends up in at least one chunk.
+type Chunker struct {
+	Counter   Counter
+	MaxTokens int
+	Overlap   int
+}
+
+func New(counter Counter, maxTokens, overlap int) *Chunker {
+	return &Chunker{Counter: counter, MaxTokens: maxTokens, Overlap: overlap}
+}
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	sentenceEnd    = regexp.MustCompile(`[.!?…。！？]+["'”’)\]]*\s+`)
+	whitespace     = regexp.MustCompile(`\s+`)
+
+	separators = []*regexp.Regexp{paragraphBreak, sentenceEnd, whitespace}
+)
+
+// unit is an atomic span of the input that fits in one chunk.
+type unit struct {
+	start, end int
+	tokens     int
+}
+
+// Split returns the chunk texts. It never fails; the error is there to satisfy Splitter.
+func (c *Chunker) Split(ctx context.Context, text string) ([]string, error) {
+	chunks := c.Chunks(text)
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	return texts, nil
+}
+
+// Chunks splits text and reports where each chunk came from. Chunks are trimmed of
+// surrounding whitespace; whitespace-only chunks are dropped.
+func (c *Chunker) Chunks(text string) []Chunk {
+	maxTokens := c.MaxTokens
+	if maxTokens < 1 {
+		maxTokens = 1
+	}
+	counter := c.Counter
+	if counter == nil {
+		counter = Approx
+	}
+
+	units := c.units(text, 0, len(text), 0, counter, maxTokens)
+
+	var chunks []Chunk
+	for first := 0; first < len(units); {
+		// Unit token counts are summed rather than re-counting the joined text. Cuts
+		// fall after whitespace, where BPE merges rarely span, so the sum is at worst
+		// a slight overestimate.
+		tokens, last := 0, first
+		for last < len(units) && tokens+units[last].tokens <= maxTokens {
+			tokens += units[last].tokens
+			last++
+		}
+		if last == first {
+			last++
+		}
+
+		if chunk, ok := trimmed(text, units[first].start, units[last-1].end); ok {
+			chunks = append(chunks, chunk)
+		}
+		if last == len(units) {
+			break
+		}
+
+		next := last
+		overlap := 0
+		for next-1 > first && overlap+units[next-1].tokens <= c.Overlap &&
+			overlap+units[next-1].tokens+units[last].tokens <= maxTokens {
+			next--
+			overlap += units[next].tokens
+		}
+		first = next
+	}
+	return chunks
+}
+
+// units cuts text[start:end] into spans that each fit in maxTokens, splitting on the
+// separator at level and recursing into pieces that are still too big.
+func (c *Chunker) units(text string, start, end, level int, counter Counter, maxTokens int) []unit {
+	tokens := counter.Count(text[start:end])
+	if tokens <= maxTokens {
+		return []unit{{start: start, end: end, tokens: tokens}}
+	}
+	if level == len(separators) {
+		return splitRunes(text, start, end, counter, maxTokens)
+	}
+
+	var units []unit
+	pieceStart := start
+	for _, loc := range separators[level].FindAllStringIndex(text[start:end], -1) {
+		cut := start + loc[1]
+		if cut == end {
+			break
+		}
+		units = append(units, c.units(text, pieceStart, cut, level+1, counter, maxTokens)...)
+		pieceStart = cut
+	}
+	return append(units, c.units(text, pieceStart, end, level+1, counter, maxTokens)...)
+}
+
+// splitRunes cuts an oversized word into pieces on rune boundaries, sized in
+// proportion to its token count and halved until each piece fits.
+func splitRunes(text string, start, end int, counter Counter, maxTokens int) []unit {
+	var units []unit
+	total := counter.Count(text[start:end])
+	size := utf8.RuneCountInString(text[start:end]) * maxTokens / total
+	for start < end {
+		n := size
+		for {
+			cut := advanceRunes(text, start, end, n)
+			tokens := counter.Count(text[start:cut])
+			if tokens <= maxTokens || n <= 1 {
+				units = append(units, unit{start: start, end: cut, tokens: tokens})
+				start = cut
+				break
+			}
+			n /= 2
+		}
+	}
+	return units
+}
+
+func advanceRunes(text string, start, end, n int) int {
+	if n < 1 {
+		n = 1
+	}
+	for i := range text[start:end] {
+		if n == 0 {
+			return start + i
+		}
+		n--
+	}
+	return end
+}
+
+func trimmed(text string, start, end int) (Chunk, bool) {
+	s := text[start:end]
+	trimmedLeft := strings.TrimLeftFunc(s, unicode.IsSpace)
+	start += len(s) - len(trimmedLeft)
+	end = start + len(strings.TrimRightFunc(trimmedLeft, unicode.IsSpace))
+	if start == end {
+		return Chunk{}, false
+	}
+	return Chunk{Text: text[start:end], Start: start, End: end}, true
+}
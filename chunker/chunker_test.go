@@ -0,0 +1,255 @@
+package chunker
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// words counts whitespace-separated words, a counter whose units differ from Approx.
+var words = CounterFunc(func(text string) int { return len(strings.Fields(text)) })
+
+// randomText builds paragraphs of sentences with the odd overlong word, mixed
+// whitespace and multi-byte runes, so every separator level and splitRunes get used.
+func randomText(r *rand.Rand) string {
+	vocab := []string{"the", "audio", "pipeline", "größe", "naïve", "日本語", "a", "retrieval", "chunk", "ok"}
+	ends := []string{". ", "! ", "? ", "… ", ".\" ", "。"}
+	var sb strings.Builder
+	for p := r.Intn(6); p >= 0; p-- {
+		for s := r.Intn(8); s >= 0; s-- {
+			for w := r.Intn(15); w >= 0; w-- {
+				if r.Intn(40) == 0 {
+					sb.WriteString(strings.Repeat("x", 20+r.Intn(200)))
+				} else {
+					sb.WriteString(vocab[r.Intn(len(vocab))])
+				}
+				sb.WriteString([]string{" ", " ", " ", "\t", "  "}[r.Intn(5)])
+			}
+			sb.WriteString(ends[r.Intn(len(ends))])
+		}
+		sb.WriteString([]string{"\n\n", "\n \n", "\n\n\n", "\n"}[r.Intn(4)])
+	}
+	return sb.String()
+}
+
+// checkChunks verifies the Chunks invariants for one input.
+func checkChunks(t *testing.T, c *Chunker, text string) {
+	t.Helper()
+	chunks := c.Chunks(text)
+	if strings.TrimSpace(text) == "" {
+		if len(chunks) != 0 {
+			t.Fatalf("whitespace input gave %d chunks", len(chunks))
+		}
+		return
+	}
+	if len(chunks) == 0 {
+		t.Fatalf("no chunks for %q", text)
+	}
+
+	var rejoined strings.Builder
+	prevStart, prevEnd := -1, -1
+	for i, chunk := range chunks {
+		if chunk.Start < 0 || chunk.End > len(text) || chunk.Start >= chunk.End {
+			t.Fatalf("chunk %d has bad offsets [%d:%d] in %d bytes", i, chunk.Start, chunk.End, len(text))
+		}
+		if chunk.Text != text[chunk.Start:chunk.End] {
+			t.Fatalf("chunk %d text %q != text[%d:%d] %q", i, chunk.Text, chunk.Start, chunk.End, text[chunk.Start:chunk.End])
+		}
+		if chunk.Text != strings.TrimSpace(chunk.Text) {
+			t.Fatalf("chunk %d is not trimmed: %q", i, chunk.Text)
+		}
+		if chunk.Start < prevStart || chunk.End <= prevEnd {
+			t.Fatalf("chunk %d [%d:%d] goes back from [%d:%d]", i, chunk.Start, chunk.End, prevStart, prevEnd)
+		}
+		if n := c.Counter.Count(chunk.Text); n > c.MaxTokens {
+			t.Fatalf("chunk %d has %d tokens, budget is %d: %q", i, n, c.MaxTokens, chunk.Text)
+		}
+
+		// Drop the part shared with the previous chunk; whatever lies between two
+		// chunks must be whitespace.
+		from := chunk.Start
+		if i > 0 {
+			if gap := text[prevEnd:max(prevEnd, chunk.Start)]; strings.TrimSpace(gap) != "" {
+				t.Fatalf("text %q between chunks %d and %d was dropped", gap, i-1, i)
+			}
+			rejoined.WriteString(text[prevEnd:max(prevEnd, chunk.Start)])
+			from = max(prevEnd, chunk.Start)
+		}
+		rejoined.WriteString(text[from:chunk.End])
+		prevStart, prevEnd = chunk.Start, chunk.End
+	}
+	if got, want := rejoined.String(), strings.TrimSpace(text); got != want {
+		t.Fatalf("rejoined chunks differ from input\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestChunksRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		text := randomText(r)
+		counter := Approx
+		if i%2 == 1 {
+			counter = words
+		}
+		c := New(counter, 1+r.Intn(60), r.Intn(20))
+		checkChunks(t, c, text)
+	}
+}
+
+func TestChunksPrefersParagraphs(t *testing.T) {
+	text := "First paragraph, one sentence.\n\nSecond paragraph. It has two sentences."
+	chunks := New(words, 8, 0).Chunks(text)
+	want := []string{"First paragraph, one sentence.", "Second paragraph. It has two sentences."}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks %+v, want %d", len(chunks), chunks, len(want))
+	}
+	for i := range want {
+		if chunks[i].Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i].Text, want[i])
+		}
+	}
+}
+
+func TestChunksOverlap(t *testing.T) {
+	text := "one two three four five six seven eight"
+	chunks := New(words, 4, 2).Chunks(text)
+	want := []string{"one two three four", "three four five six", "five six seven eight"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks %+v, want %d", len(chunks), chunks, len(want))
+	}
+	for i := range want {
+		if chunks[i].Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i].Text, want[i])
+		}
+	}
+}
+
+func FuzzChunks(f *testing.F) {
+	f.Add("Hello world. This is a test.\n\nNew paragraph!", 5, 2)
+	f.Add(strings.Repeat("x", 100), 3, 1)
+	f.Add("größe 日本語。 naïve\t\n \n…", 1, 0)
+	f.Add("", 10, 0)
+	f.Fuzz(func(t *testing.T, text string, maxTokens, overlap int) {
+		if maxTokens < 1 || maxTokens > 200 || overlap < 0 || overlap > 200 {
+			t.Skip()
+		}
+		checkChunks(t, New(Approx, maxTokens, overlap), text)
+	})
+}
+
+// topicEmbedder embeds text as counts of a few topic words, so sentences about the
+// same topic are close and a change of topic is a large distance.
+type topicEmbedder struct{ topics []string }
+
+func (e topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(e.topics))
+		for j, topic := range e.topics {
+			vectors[i][j] = float32(strings.Count(text, topic))
+		}
+	}
+	return vectors, nil
+}
+
+// checkSemantic verifies that chunks appear in order, fit the budget and between them
+// cover every non-whitespace character of text.
+func checkSemantic(t *testing.T, s *Semantic, text string, chunks []string) {
+	t.Helper()
+	pos := 0
+	for i, chunk := range chunks {
+		if n := s.Counter.Count(chunk); n > s.MaxTokens {
+			t.Fatalf("chunk %d has %d tokens, budget is %d", i, n, s.MaxTokens)
+		}
+		at := strings.Index(text[pos:], chunk)
+		if at < 0 {
+			t.Fatalf("chunk %d %q not found in order", i, chunk)
+		}
+		if skipped := text[pos : pos+at]; strings.TrimSpace(skipped) != "" {
+			t.Fatalf("text %q before chunk %d was dropped", skipped, i)
+		}
+		pos += at + len(chunk)
+	}
+	if rest := text[pos:]; strings.TrimSpace(rest) != "" {
+		t.Fatalf("trailing text %q was dropped", rest)
+	}
+}
+
+func TestSemanticSplitsOnTopicShift(t *testing.T) {
+	cats := "Cats sleep a lot. Cats purr when happy. Cats chase mice. "
+	rockets := "Rockets burn fuel. Rockets reach orbit. Rockets carry satellites. "
+	text := cats + rockets + cats
+	s := &Semantic{
+		Embedder:   topicEmbedder{[]string{"Cats", "Rockets"}},
+		Counter:    words,
+		MinTokens:  3,
+		MaxTokens:  100,
+		Percentile: 80,
+		Buffer:     0,
+	}
+	chunks, err := s.Split(context.Background(), text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSemantic(t, s, text, chunks)
+	want := []string{strings.TrimSpace(cats), strings.TrimSpace(rockets), strings.TrimSpace(cats)}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks %q, want %q", len(chunks), chunks, want)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+}
+
+func TestSemanticRandomized(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	embedder := topicEmbedder{[]string{"audio", "pipeline", "chunk", "x"}}
+	for i := 0; i < 200; i++ {
+		text := randomText(r)
+		s := &Semantic{
+			Embedder:   embedder,
+			Counter:    Approx,
+			MinTokens:  r.Intn(20),
+			MaxTokens:  1 + r.Intn(80),
+			Percentile: float64(r.Intn(101)),
+			Buffer:     r.Intn(3),
+		}
+		chunks, err := s.Split(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSemantic(t, s, text, chunks)
+		for j, chunk := range chunks {
+			if chunk != strings.TrimFunc(chunk, unicode.IsSpace) || chunk == "" {
+				t.Fatalf("chunk %d is empty or untrimmed: %q", j, chunk)
+			}
+		}
+	}
+}
+
+func FuzzSemantic(f *testing.F) {
+	f.Add("Cats purr. Cats sleep. Rockets fly. Rockets burn.", 2, 20)
+	f.Add("a. b. c.\n\nd! e? f…", 0, 1)
+	f.Fuzz(func(t *testing.T, text string, minTokens, maxTokens int) {
+		if minTokens < 0 || maxTokens < 1 || maxTokens > 200 {
+			t.Skip()
+		}
+		s := &Semantic{
+			Embedder:   topicEmbedder{[]string{"a", "e", "Cats", "Rockets"}},
+			Counter:    Approx,
+			MinTokens:  minTokens,
+			MaxTokens:  maxTokens,
+			Percentile: 90,
+			Buffer:     1,
+		}
+		chunks, err := s.Split(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSemantic(t, s, text, chunks)
+	})
+}
@@ -0,0 +1,44 @@
+package chunker
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Counter reports how many tokens a piece of text costs.
+type Counter interface {
+	Count(text string) int
+}
+
+// CounterFunc adapts a plain function to Counter.
+type CounterFunc func(text string) int
+
+func (f CounterFunc) Count(text string) int {
+	return f(text)
+}
+
+// Approx estimates tokens as one per four characters, which is close enough for
+// English with BPE tokenizers and needs no vocabulary download.
+var Approx Counter = CounterFunc(func(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+})
+
+// Tiktoken counts tokens with a tiktoken encoding such as "cl100k_base". The BPE
+// ranks are downloaded on first use unless a tiktoken loader has been configured.
+type Tiktoken struct {
+	enc *tiktoken.Tiktoken
+}
+
+func NewTiktoken(encoding string) (*Tiktoken, error) {
+	enc, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tiktoken encoding %s: %w", encoding, err)
+	}
+	return &Tiktoken{enc: enc}, nil
+}
+
+func (t *Tiktoken) Count(text string) int {
+	return len(t.enc.EncodeOrdinary(text))
+}
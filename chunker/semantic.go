@@ -0,0 +1,169 @@
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Embedder turns text into vectors, one per input text. pipeline.Embedder
+// implementations satisfy it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+var sentenceBreak = regexp.MustCompile(paragraphBreak.String() + `|` + sentenceEnd.String())
+
+// Semantic cuts text where the topic shifts. Every sentence is embedded together with
+// Buffer neighbours on each side, and a chunk ends after a sentence whose distance to
+// the next one is above the Percentile of all adjacent distances. Chunks are kept
+// between MinTokens and MaxTokens: a shift is ignored until the chunk has MinTokens,
+// and a chunk is cut regardless once the next sentence would push it over MaxTokens.
+type Semantic struct {
+	Embedder   Embedder
+	Counter    Counter
+	MinTokens  int
+	MaxTokens  int
+	Percentile float64
+	Buffer     int
+}
+
+func NewSemantic(embedder Embedder, counter Counter) *Semantic {
+	return &Semantic{
+		Embedder:   embedder,
+		Counter:    counter,
+		MinTokens:  100,
+		MaxTokens:  1000,
+		Percentile: 95,
+		Buffer:     1,
+	}
+}
+
+type sentence struct {
+	start, end int
+	tokens     int
+}
+
+func (s *Semantic) Split(ctx context.Context, text string) ([]string, error) {
+	counter := s.Counter
+	if counter == nil {
+		counter = Approx
+	}
+	sentences := s.sentences(text, counter)
+	if len(sentences) < 3 {
+		return New(counter, s.MaxTokens, 0).Split(ctx, text)
+	}
+
+	windows := make([]string, len(sentences))
+	for i := range sentences {
+		from, to := max(0, i-s.Buffer), min(len(sentences)-1, i+s.Buffer)
+		windows[i] = strings.TrimSpace(text[sentences[from].start:sentences[to].end])
+	}
+	vectors, err := s.Embedder.Embed(ctx, windows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(vectors) != len(windows) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d sentences", len(vectors), len(windows))
+	}
+
+	distances := make([]float64, len(vectors)-1)
+	for i := range distances {
+		distances[i] = 1 - cosine(vectors[i], vectors[i+1])
+	}
+	threshold := percentile(distances, s.Percentile)
+
+	var groups [][2]int
+	first, tokens := 0, sentences[0].tokens
+	for i := 1; i < len(sentences); i++ {
+		shift := distances[i-1] > threshold && tokens >= s.MinTokens
+		if shift || tokens+sentences[i].tokens > s.MaxTokens {
+			groups = append(groups, [2]int{first, i})
+			first, tokens = i, 0
+		}
+		tokens += sentences[i].tokens
+	}
+	// A short tail is folded into the previous chunk when it fits.
+	if n := len(groups); n > 0 && tokens < s.MinTokens && groupTokens(sentences, groups[n-1])+tokens <= s.MaxTokens {
+		first = groups[n-1][0]
+		groups = groups[:n-1]
+	}
+	groups = append(groups, [2]int{first, len(sentences)})
+
+	chunks := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if chunk, ok := trimmed(text, sentences[g[0]].start, sentences[g[1]-1].end); ok {
+			chunks = append(chunks, chunk.Text)
+		}
+	}
+	return chunks, nil
+}
+
+// sentences cuts text after paragraph breaks and sentence ends. Sentences longer than
+// MaxTokens are split further by the fixed-size chunker. A sentence's tokens include
+// the whitespace before it, which ends up inside the chunk when it is grouped.
+func (s *Semantic) sentences(text string, counter Counter) []sentence {
+	fixed := New(counter, s.MaxTokens, 0)
+
+	var sentences []sentence
+	prevEnd := 0
+	add := func(start, end int) {
+		for _, c := range fixed.Chunks(text[start:end]) {
+			tokens := counter.Count(text[prevEnd : start+c.End])
+			if len(sentences) == 0 {
+				tokens = counter.Count(c.Text)
+			}
+			sentences = append(sentences, sentence{start: start + c.Start, end: start + c.End, tokens: tokens})
+			prevEnd = start + c.End
+		}
+	}
+	start := 0
+	for _, loc := range sentenceBreak.FindAllStringIndex(text, -1) {
+		add(start, loc[1])
+		start = loc[1]
+	}
+	add(start, len(text))
+	return sentences
+}
+
+func groupTokens(sentences []sentence, g [2]int) int {
+	tokens := 0
+	for _, s := range sentences[g[0]:g[1]] {
+		tokens += s.tokens
+	}
+	return tokens
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile returns the p-th percentile of values using linear interpolation.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo < 0 {
+		return sorted[0]
+	}
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
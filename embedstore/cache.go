@@ -0,0 +1,223 @@
+package embedstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheKey identifies an embedding: sha256 of model|task|title|text. Task type and
+// title are part of the key because Gemini returns different vectors for them.
+type CacheKey [sha256.Size]byte
+
+func NewCacheKey(model string, task TaskType, title, text string) CacheKey {
+	return sha256.Sum256([]byte(model + "|" + string(task) + "|" + title + "|" + text))
+}
+
+// maxCachedDimension guards against reading a garbage length from a damaged file.
+const maxCachedDimension = 1 << 16
+
+// recordMagic starts every record so load can find the next one after damage.
+var recordMagic = [4]byte{'E', 'M', 'B', '1'}
+
+// recordHeader is the magic, the CRC-32 of the rest of the record, the key and the
+// dimension.
+const recordHeader = 4 + 4 + sha256.Size + 4
+
+// EmbeddingCache keeps vectors in memory and appends every new one to a file, so
+// chunks seen in earlier runs are never embedded twice. Each record is a 4-byte magic,
+// a little-endian CRC-32 of the rest, the 32-byte key, a little-endian uint32
+// dimension and that many little-endian float32s. Damaged records, e.g. one torn by
+// a crash, are skipped when the file is opened and the file is rewritten without them.
+type EmbeddingCache struct {
+	mu      sync.RWMutex
+	file    *os.File
+	size    int64 // offset just past the last good record
+	vectors map[CacheKey][]float32
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// OpenEmbeddingCache loads the cache file at path, creating it if needed.
+func OpenEmbeddingCache(path string) (*EmbeddingCache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening embedding cache: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading embedding cache %s: %w", path, err)
+	}
+
+	c := &EmbeddingCache{file: f, vectors: make(map[CacheKey][]float32)}
+	keys, end, damaged := c.load(data)
+	if damaged {
+		// Records follow the damage, so cutting the file short would lose them.
+		f.Close()
+		log.Printf("embedding cache %s has damaged records, rewriting it with %d vectors", path, len(keys))
+		if c.file, err = c.rewrite(path, keys); err != nil {
+			return nil, fmt.Errorf("error repairing embedding cache %s: %w", path, err)
+		}
+		return c, nil
+	}
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error repairing embedding cache %s: %w", path, err)
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.size = end
+	return c, nil
+}
+
+// load decodes every intact record in data, in file order. end is the offset just
+// past the last one; damaged reports garbage before end, which truncating at end
+// would not remove.
+func (c *EmbeddingCache) load(data []byte) (keys []CacheKey, end int64, damaged bool) {
+	for offset := 0; offset < len(data); {
+		key, vector, n := decodeRecord(data[offset:])
+		if n == 0 {
+			next := bytes.Index(data[offset+1:], recordMagic[:])
+			if next < 0 {
+				break
+			}
+			offset += 1 + next
+			damaged = true
+			continue
+		}
+		if _, ok := c.vectors[key]; !ok {
+			keys = append(keys, key)
+		}
+		c.vectors[key] = vector
+		offset += n
+		end = int64(offset)
+	}
+	return keys, end, damaged
+}
+
+// rewrite replaces the file at path with the cached vectors of keys and returns it
+// opened for appending.
+func (c *EmbeddingCache) rewrite(path string, keys []CacheKey) (*os.File, error) {
+	var buf bytes.Buffer
+	for _, key := range keys {
+		buf.Write(encodeRecord(key, c.vectors[key]))
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.size = int64(buf.Len())
+	return f, nil
+}
+
+func encodeRecord(key CacheKey, vector []float32) []byte {
+	record := make([]byte, recordHeader+4*len(vector))
+	copy(record, recordMagic[:])
+	copy(record[8:], key[:])
+	binary.LittleEndian.PutUint32(record[8+len(key):], uint32(len(vector)))
+	for i, x := range vector {
+		binary.LittleEndian.PutUint32(record[recordHeader+4*i:], math.Float32bits(x))
+	}
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(record[8:]))
+	return record
+}
+
+// decodeRecord reads the record at the start of data and returns its length, or 0 if
+// data does not start with an intact record.
+func decodeRecord(data []byte) (CacheKey, []float32, int) {
+	var key CacheKey
+	if len(data) < recordHeader || !bytes.Equal(data[:4], recordMagic[:]) {
+		return key, nil, 0
+	}
+	dim := binary.LittleEndian.Uint32(data[8+len(key):])
+	if dim == 0 || dim > maxCachedDimension || len(data) < recordHeader+4*int(dim) {
+		return key, nil, 0
+	}
+	n := recordHeader + 4*int(dim)
+	if crc32.ChecksumIEEE(data[8:n]) != binary.LittleEndian.Uint32(data[4:]) {
+		return key, nil, 0
+	}
+	copy(key[:], data[8:])
+	vector := make([]float32, dim)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[recordHeader+4*i:]))
+	}
+	return key, vector, n
+}
+
+// Get returns the cached vector for key and counts the hit or miss.
+func (c *EmbeddingCache) Get(key CacheKey) ([]float32, bool) {
+	c.mu.RLock()
+	vector, ok := c.vectors[key]
+	c.mu.RUnlock()
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return vector, ok
+}
+
+// Put stores vector under key and appends it to the cache file.
+func (c *EmbeddingCache) Put(key CacheKey, vector []float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.vectors[key]; ok {
+		return nil
+	}
+
+	record := encodeRecord(key, vector)
+	if _, err := c.file.Write(record); err != nil {
+		// Cut off whatever part of the record made it to disk so the next record
+		// does not land after a torn one.
+		if terr := c.file.Truncate(c.size); terr == nil {
+			c.file.Seek(c.size, io.SeekStart)
+		}
+		return fmt.Errorf("error writing embedding cache: %w", err)
+	}
+	c.size += int64(len(record))
+	c.vectors[key] = vector
+	return nil
+}
+
+// Len returns the number of cached vectors.
+func (c *EmbeddingCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.vectors)
+}
+
+// Stats returns the hit and miss counts since the cache was opened.
+func (c *EmbeddingCache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *EmbeddingCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
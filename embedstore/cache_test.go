@@ -0,0 +1,109 @@
+package embedstore
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func cacheVectors() (keys []CacheKey, vectors [][]float32) {
+	for i, text := range []string{"first", "second", "third", "fourth"} {
+		keys = append(keys, NewCacheKey("model", TaskRetrievalDocument, "title", text))
+		vectors = append(vectors, []float32{float32(i), 0.5, -1, float32(i) / 3})
+	}
+	return keys, vectors
+}
+
+func openCache(t *testing.T, path string) *EmbeddingCache {
+	t.Helper()
+	c, err := OpenEmbeddingCache(path)
+	if err != nil {
+		t.Fatalf("OpenEmbeddingCache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// checkCache asserts that c holds exactly the vectors at the given indexes.
+func checkCache(t *testing.T, c *EmbeddingCache, want ...int) {
+	t.Helper()
+	keys, vectors := cacheVectors()
+	if c.Len() != len(want) {
+		t.Errorf("cache holds %d vectors, want %d", c.Len(), len(want))
+	}
+	for _, i := range want {
+		got, ok := c.Get(keys[i])
+		if !ok || !reflect.DeepEqual(got, vectors[i]) {
+			t.Errorf("vector %d = %v, %v; want %v", i, got, ok, vectors[i])
+		}
+	}
+}
+
+func TestEmbeddingCacheReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.bin")
+	keys, vectors := cacheVectors()
+
+	c := openCache(t, path)
+	for i := range 3 {
+		if err := c.Put(keys[i], vectors[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+
+	checkCache(t, openCache(t, path), 0, 1, 2)
+}
+
+func TestEmbeddingCacheTornRecord(t *testing.T) {
+	keys, vectors := cacheVectors()
+	record := func(i int) []byte { return encodeRecord(keys[i], vectors[i]) }
+	join := func(parts ...[]byte) []byte {
+		var data []byte
+		for _, p := range parts {
+			data = append(data, p...)
+		}
+		return data
+	}
+	corrupt := record(1)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+		want []int
+		// size is the expected file length in records after opening.
+		size int
+	}{
+		{"torn at the end", join(record(0), record(1)[:30]), []int{0}, 1},
+		{"torn in the middle", join(record(0), record(1)[:30], record(2)), []int{0, 2}, 2},
+		{"torn header in the middle", join(record(0), record(1)[:3], record(2)), []int{0, 2}, 2},
+		{"bad checksum in the middle", join(record(0), corrupt, record(2)), []int{0, 2}, 2},
+		{"garbage first", join([]byte("not a cache"), record(2)), []int{2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.bin")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := openCache(t, path)
+			checkCache(t, c, tt.want...)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != int64(tt.size*len(record(0))) {
+				t.Errorf("repaired file size = %d, want %d records", info.Size(), tt.size)
+			}
+
+			// New records go after the good ones and survive another reopen.
+			if err := c.Put(keys[3], vectors[3]); err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+			checkCache(t, openCache(t, path), append(tt.want, 3)...)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package embedstore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pb "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCollection holds chunks that are not scoped to a session.
+const DefaultCollection = "embeddings"
+
+var unsafeCollectionChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// CollectionForSession returns the collection used for a tenant or session, e.g.
+// "embeddings_acme". An empty session maps to DefaultCollection.
+func CollectionForSession(session string) string {
+	session = strings.Trim(unsafeCollectionChars.ReplaceAllString(session, "_"), "_")
+	if session == "" {
+		return DefaultCollection
+	}
+	if len(session) > 64 {
+		session = session[:64]
+	}
+	return DefaultCollection + "_" + session
+}
+
+// indexedFields are the payload fields filters and lookups run against.
+var indexedFields = map[string]pb.FieldType{
+	"link":        pb.FieldType_FieldTypeKeyword,
+	"title":       pb.FieldType_FieldTypeKeyword,
+	"source":      pb.FieldType_FieldTypeKeyword,
+	"domain":      pb.FieldType_FieldTypeKeyword,
+	"session":     pb.FieldType_FieldTypeKeyword,
+	"language":    pb.FieldType_FieldTypeKeyword,
+	"ingested_at": pb.FieldType_FieldTypeInteger,
+}
+
+// EnsureCollection creates the collection with the given dimension and cosine
+// distance if it does not exist yet. An existing collection keeps its points; it only
+// gets any missing payload indexes, and an error is returned if its vector size or
+// distance differ.
+func (s *QdrantStore) EnsureCollection(ctx context.Context, dimension int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	exists, err := s.collections.CollectionExists(ctx, &pb.CollectionExistsRequest{CollectionName: s.collection()})
+	if err != nil {
+		return fmt.Errorf("failed to look up collection %s: %w", s.collection(), err)
+	}
+	if !exists.GetResult().GetExists() {
+		err = s.create(ctx, dimension)
+		if status.Code(err) != codes.AlreadyExists {
+			return err
+		}
+		// Another request created it first.
+	}
+	return s.validate(ctx, dimension)
+}
+
+// ResetCollection deletes the collection and everything in it, then recreates it empty.
+func (s *QdrantStore) ResetCollection(ctx context.Context, dimension int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.collections.Delete(ctx, &pb.DeleteCollection{CollectionName: s.collection()})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to delete collection %s: %w", s.collection(), err)
+	}
+	return s.create(ctx, dimension)
+}
+
+func (s *QdrantStore) create(ctx context.Context, dimension int) error {
+	_, err := s.collections.Create(ctx, &pb.CreateCollection{
+		CollectionName: s.collection(),
+		VectorsConfig: &pb.VectorsConfig{
+			Config: &pb.VectorsConfig_Params{
+				Params: &pb.VectorParams{
+					Size:     uint64(dimension),
+					Distance: pb.Distance_Cosine,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create collection %s: %w", s.collection(), err)
+	}
+	return s.createIndexes(ctx, nil)
+}
+
+// createIndexes adds the payload indexes that are not in existing yet.
+func (s *QdrantStore) createIndexes(ctx context.Context, existing map[string]*pb.PayloadSchemaInfo) error {
+	wait := true
+	for field, fieldType := range indexedFields {
+		if _, ok := existing[field]; ok {
+			continue
+		}
+		_, err := s.points.CreateFieldIndex(ctx, &pb.CreateFieldIndexCollection{
+			CollectionName: s.collection(),
+			FieldName:      field,
+			FieldType:      fieldType.Enum(),
+			Wait:           &wait,
+		})
+		if err != nil {
+			return fmt.Errorf("could not index %s in collection %s: %w", field, s.collection(), err)
+		}
+	}
+	return nil
+}
+
+func (s *QdrantStore) validate(ctx context.Context, dimension int) error {
+	info, err := s.collections.Get(ctx, &pb.GetCollectionInfoRequest{CollectionName: s.collection()})
+	if err != nil {
+		return fmt.Errorf("failed to get collection %s: %w", s.collection(), err)
+	}
+	params := info.GetResult().GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return fmt.Errorf("collection %s does not use a single unnamed vector", s.collection())
+	}
+	if params.GetSize() != uint64(dimension) || params.GetDistance() != pb.Distance_Cosine {
+		return fmt.Errorf("collection %s has %d-dimensional %s vectors, want %d-dimensional Cosine; reset it to change the embedding model",
+			s.collection(), params.GetSize(), params.GetDistance(), dimension)
+	}
+	return s.createIndexes(ctx, info.GetResult().GetPayloadSchema())
+}
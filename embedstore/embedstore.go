@@ -1,212 +1,79 @@
 package embedstore
 
 import (
-	"Audio-LLM-Contextual-Heygen/extract"
-	"context"
 	"fmt"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/google/uuid"
-	pb "github.com/qdrant/go-client/qdrant"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"log"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
-func StoreInQdrant(title, link string, embedding []float32, chunkStr string) {
-	conn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-	}
-	defer conn.Close()
-
-	client := pb.NewPointsClient(conn)
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	id := uuid.NewString()
-
-	payload := map[string]*pb.Value{
-		"title": {Kind: &pb.Value_StringValue{StringValue: title}},
-		"link":  {Kind: &pb.Value_StringValue{StringValue: link}},
-		"text":  {Kind: &pb.Value_StringValue{StringValue: chunkStr}},
-	}
-
-	_, err = client.Upsert(ctx, &pb.UpsertPoints{
-		CollectionName: "embeddings",
-		Points: []*pb.PointStruct{
-			{
-				Id: &pb.PointId{
-					PointIdOptions: &pb.PointId_Uuid{
-						Uuid: id,
-					},
-				},
-				Vectors: &pb.Vectors{
-					VectorsOptions: &pb.Vectors_Vector{
-						Vector: &pb.Vector{
-							Data: embedding,
-						},
-					},
-				},
-				Payload: payload,
-			},
-		},
-	})
-	if err != nil {
-		log.Fatalf("could not upsert point: %v", err)
-	}
-	// fmt.Printf("STORED: ID: %s, Payload: %+v\n", id, payload)
-}
-
-var (
-	redisClient *redis.Client
+// Source types recorded with every stored chunk.
+const (
+	SourceWeb    = "web"
+	SourceTED    = "ted"
+	SourcePDF    = "pdf"
+	SourceUpload = "upload"
 )
 
-func init() {
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:     RedisAddr,
-		Password: RedisPassword,
-		DB:       RedisDB,
-	})
-}
-
-func SetupQdrantCollection(d int) {
-	conn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-	}
-	defer conn.Close()
-
-	client := pb.NewCollectionsClient(conn)
+// TaskType is the retrieval task an embedding was computed for. Gemini embeds
+// documents and queries differently; backends without task types ignore it.
+type TaskType string
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	dimension := d
-
-	_, _ = client.Delete(ctx, &pb.DeleteCollection{
-		CollectionName: "embeddings",
-	})
-
-	_, err = client.Create(ctx, &pb.CreateCollection{
-		CollectionName: "embeddings",
-		VectorsConfig: &pb.VectorsConfig{
-			Config: &pb.VectorsConfig_Params{
-				Params: &pb.VectorParams{
-					Size:     uint64(dimension),
-					Distance: pb.Distance_Cosine,
-				},
-			},
-		},
-	})
-	if err != nil {
-		log.Fatalf("could not create collection: %v", err)
-	}
-}
-
-func SearchQdrant(queryEmbedding []float32, limit int, scoreThreshold float32) ([]string, error) {
-	conn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, fmt.Errorf("did not connect: %w", err)
-	}
-	defer conn.Close()
-
-	client := pb.NewPointsClient(conn)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	searchResult, err := client.Search(ctx, &pb.SearchPoints{
-		CollectionName: "embeddings",
-		Vector:         queryEmbedding,
-		Limit:          uint64(limit),
-		WithPayload: &pb.WithPayloadSelector{
-			SelectorOptions: &pb.WithPayloadSelector_Enable{
-				Enable: true,
-			},
-		},
-		ScoreThreshold: &scoreThreshold,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to search Qdrant: %w", err)
-	}
-
-	var chunkIDs []string
-	for _, result := range searchResult.Result {
-		chunkIDs = append(chunkIDs, result.Id.GetUuid())
-		// fmt.Println("SEARCH RESS : ", result.GetPayload(), result)
-	}
-
-	return chunkIDs, nil
-}
+const (
+	TaskRetrievalDocument  TaskType = "RETRIEVAL_DOCUMENT"
+	TaskRetrievalQuery     TaskType = "RETRIEVAL_QUERY"
+	TaskSemanticSimilarity TaskType = "SEMANTIC_SIMILARITY"
+)
 
 type ChunkData struct {
-	Title string
-	Link  string
-	Text  string
+	Title  string
+	Link   string
+	Text   string
+	Source string
+	// Index is the chunk's position within its document.
+	Index int
+	// Page is the 1-based PDF page the chunk came from, 0 otherwise.
+	Page int
+	// Start and End locate transcript chunks in their media; End is 0 for text sources.
+	Start time.Duration
+	End   time.Duration
+	// Model and TaskType record how the chunk's vector was computed so collections
+	// holding vectors from different models can be detected.
+	Model    string
+	TaskType TaskType
+	// Language is the document's language code when known, e.g. "en".
+	Language string
+	// Session is the session or tenant the chunk was ingested for, if any.
+	Session    string
+	IngestedAt time.Time
+	// Score is the similarity to the query for chunks returned by a search, 0 otherwise.
+	Score float32
+	// Vector is the stored embedding of a search hit. It is never written to the
+	// payload.
+	Vector []float32
 }
 
-func GetChunks(chunkIDs []string) ([]ChunkData, error) {
-	conn, err := grpc.Dial("localhost:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, fmt.Errorf("did not connect: %w", err)
+// Citation is the name used for the chunk in the prompt's source list, e.g.
+// "file.pdf p.12" or "meeting.mp4 @ 04:31".
+func (c ChunkData) Citation() string {
+	switch {
+	case c.Page > 0:
+		return fmt.Sprintf("%s p.%d", c.Title, c.Page)
+	case c.End > 0:
+		return fmt.Sprintf("%s @ %s", c.Title, FormatTimestamp(c.Start))
 	}
-	defer conn.Close()
-
-	client := pb.NewPointsClient(conn)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var chunks []ChunkData
-	for _, chunkID := range chunkIDs {
-		pointID := &pb.PointId{
-			PointIdOptions: &pb.PointId_Uuid{
-				Uuid: chunkID,
-			},
-		}
-
-		response, err := client.Get(ctx, &pb.GetPoints{
-			CollectionName: "embeddings",
-			Ids:            []*pb.PointId{pointID},
-			WithPayload: &pb.WithPayloadSelector{
-				SelectorOptions: &pb.WithPayloadSelector_Enable{
-					Enable: true,
-				},
-			},
-		})
-		if err != nil {
-			log.Printf("failed to retrieve point for chunk ID %s: %v", chunkID, err)
-			continue
-		}
-
-		// fmt.Printf("Response for chunk ID %s: %+v\n", chunkID, response.GetResult())
-
-		for _, point := range response.Result {
-			payload := point.Payload
+	return c.Title
+}
 
-			if text, ok := payload["text"]; ok {
-				textContent := text.GetStringValue()
-				if !strings.Contains(textContent, "::") && !strings.Contains(textContent, "{") && !strings.Contains(textContent, "}") {
-					cdata := ChunkData{
-						Title: payload["title"].GetStringValue(),
-						Link:  payload["link"].GetStringValue(),
-						Text:  textContent,
-					}
-					chunks = append(chunks, cdata)
-				} else {
-					log.Printf("filtered out noisy text for chunk ID %s", chunkID)
-				}
-			} else {
-				log.Printf("text field not found in payload for chunk ID %s", chunkID)
-			}
-		}
+// FormatTimestamp renders d as MM:SS, or H:MM:SS from one hour on.
+func FormatTimestamp(d time.Duration) string {
+	total := int(d / time.Second)
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
 	}
-
-	return chunks, nil
+	return fmt.Sprintf("%02d:%02d", m, s)
 }
 
 type Document struct {
@@ -238,50 +105,6 @@ func ChunkToDocuments(chunks []ChunkData, maxTokens int) []Document {
 	return documents
 }
 
-func SplitContentByBytes(content string, maxBytes int) []string {
-	var chunks []string
-	var currentChunk strings.Builder
-	currentSize := 0
-
-	words := strings.Fields(content)
-	for _, word := range words {
-		wordSize := utf8.RuneCountInString(word)
-		if currentSize+wordSize+1 > maxBytes {
-			if currentChunk.Len() > 0 {
-				chunks = append(chunks, currentChunk.String())
-				currentChunk.Reset()
-				currentSize = 0
-			}
-
-			if wordSize > maxBytes {
-				for start := 0; start < len(word); {
-					end := start + maxBytes
-					if end > len(word) {
-						end = len(word)
-					}
-					chunks = append(chunks, word[start:end])
-					start = end
-				}
-			} else {
-				currentChunk.WriteString(word)
-				currentSize = wordSize
-			}
-		} else {
-			if currentChunk.Len() > 0 {
-				currentChunk.WriteString(" ")
-				currentSize++
-			}
-			currentChunk.WriteString(word)
-			currentSize += wordSize
-		}
-	}
-	if currentChunk.Len() > 0 && currentChunk.Len() < 9990 {
-		chunks = append(chunks, currentChunk.String())
-	}
-
-	return chunks
-}
-
 func SanitizeUTF8(input string) string {
 	if utf8.ValidString(input) {
 		return input
@@ -299,61 +122,10 @@ func SanitizeUTF8(input string) string {
 	return string(v)
 }
 
-var totalChunks = 0
-
 type Result struct {
-	Title string `json:"title"`
-	Link  string `json:"link"`
-	IsTED bool   `json:"isted"`
-}
-
-func GetGeminiEmbedding(ctx context.Context, client *genai.Client, content, model, title string, result Result, isQuery bool) []float32 {
-	const maxBytes = 9000
-	const maxChunks = 5
-
-	// var textsplitters textsplitter.TextSplitter
-	// textsplitters = textsplitter.NewRecursiveCharacter(
-	// 	textsplitter.WithChunkSize(1000),
-	// 	textsplitter.WithChunkOverlap(200),
-	// 	textsplitter.WithSeparators([]string{" "}),
-	// )
-
-	// splitDocuments, err := textsplitter.SplitDocuments(textsplitters, []schema.Document{document})
-	// if err != nil {
-	// 	fmt.Errorf("err")
-	// }
-
-	chunks := SplitContentByBytes(content, maxBytes)
-	// fmt.Println("SPLIT DOCS  : ", chunks)
-	processedChunks := 0
-	// var combinedEmbedding []float32
-	for _, chunk := range chunks {
-		if processedChunks >= maxChunks {
-			break
-		}
-		chunk = SanitizeUTF8(chunk)
-		fmt.Printf("Processing chunk %d of size %d bytes\n", processedChunks, len(chunk))
-		em := client.EmbeddingModel(model)
-		res, err := em.EmbedContent(ctx, genai.Text(chunk))
-		if err != nil {
-			fmt.Printf("failed to generate embedding: %w", err)
-			continue
-		}
-		if res.Embedding != nil && res.Embedding.Values != nil && chunk != "" {
-			StoreInQdrant(result.Title, result.Link, res.Embedding.Values, chunk)
-
-			processedChunks++
-			totalChunks++
-		}
-		if isQuery {
-			var combinedEmbedding []float32
-			combinedEmbedding = append(combinedEmbedding, res.Embedding.Values...)
-			extract.CacheEmbedding(combinedEmbedding)
-			return combinedEmbedding
-		}
-
-	}
-	// fmt.Println("CHONSKSS: ", chunks)
-	return nil
-
+	Title   string   `json:"title"`
+	Link    string   `json:"link"`
+	IsTED   bool     `json:"isted"`
+	Sources []string `json:"sources,omitempty"`
+	Score   float64  `json:"score,omitempty"`
 }
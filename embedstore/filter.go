@@ -0,0 +1,131 @@
+package embedstore
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// Filter restricts which chunks a search or delete sees. Empty fields match
+// everything; a field with several values matches any of them, and all non-empty
+// fields must match. For example, Filter{Sources: []string{SourcePDF, SourceUpload}}
+// keeps an avatar to the documents uploaded for its meeting.
+type Filter struct {
+	Sources []string
+	Links   []string
+	// Domains match the link's host without "www.", e.g. "ted.com". Values are
+	// compared case-insensitively and may carry "www." themselves.
+	Domains   []string
+	Sessions  []string
+	Languages []string
+	// IngestedAfter and IngestedBefore bound IngestedAt; zero means unbounded.
+	IngestedAfter  time.Time
+	IngestedBefore time.Time
+}
+
+// IsEmpty reports whether f matches every chunk.
+func (f Filter) IsEmpty() bool {
+	return len(f.Sources) == 0 && len(f.Links) == 0 && len(f.Domains) == 0 &&
+		len(f.Sessions) == 0 && len(f.Languages) == 0 &&
+		f.IngestedAfter.IsZero() && f.IngestedBefore.IsZero()
+}
+
+// Match reports whether chunk passes f. It is what the in-memory store uses; the
+// Qdrant store sends f as conditions instead.
+func (f Filter) Match(chunk ChunkData) bool {
+	switch {
+	case len(f.Sources) > 0 && !slices.Contains(f.Sources, chunk.Source),
+		len(f.Links) > 0 && !slices.Contains(f.Links, chunk.Link),
+		len(f.Domains) > 0 && !f.matchDomain(chunk.Domain()),
+		len(f.Sessions) > 0 && !slices.Contains(f.Sessions, chunk.Session),
+		len(f.Languages) > 0 && !slices.Contains(f.Languages, chunk.Language),
+		!f.IngestedAfter.IsZero() && chunk.IngestedAt.Before(f.IngestedAfter),
+		!f.IngestedBefore.IsZero() && !chunk.IngestedAt.Before(f.IngestedBefore):
+		return false
+	}
+	return true
+}
+
+func (f Filter) matchDomain(domain string) bool {
+	return slices.ContainsFunc(f.Domains, func(d string) bool { return normalizeDomain(d) == domain })
+}
+
+// Domain returns the host of the chunk's link, lowercased and without "www.", or ""
+// for uploads and unparseable links.
+func (c ChunkData) Domain() string {
+	u, err := url.Parse(c.Link)
+	if err != nil || u.Scheme == "upload" {
+		return ""
+	}
+	return normalizeDomain(u.Hostname())
+}
+
+// normalizeDomain brings a host into the form Domain returns.
+func normalizeDomain(host string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "www.")
+}
+
+func normalizeDomains(hosts []string) []string {
+	out := make([]string, len(hosts))
+	for i, host := range hosts {
+		out[i] = normalizeDomain(host)
+	}
+	return out
+}
+
+// qdrantFilter translates f into Qdrant conditions, or nil if f is empty.
+func (f Filter) qdrantFilter() *pb.Filter {
+	if f.IsEmpty() {
+		return nil
+	}
+	var must []*pb.Condition
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"source", f.Sources},
+		{"link", f.Links},
+		{"domain", normalizeDomains(f.Domains)},
+		{"session", f.Sessions},
+		{"language", f.Languages},
+	} {
+		if len(field.values) > 0 {
+			must = append(must, keywordsCondition(field.name, field.values))
+		}
+	}
+	if !f.IngestedAfter.IsZero() || !f.IngestedBefore.IsZero() {
+		r := &pb.Range{}
+		if !f.IngestedAfter.IsZero() {
+			gte := float64(f.IngestedAfter.Unix())
+			r.Gte = &gte
+		}
+		if !f.IngestedBefore.IsZero() {
+			lt := float64(f.IngestedBefore.Unix())
+			r.Lt = &lt
+		}
+		must = append(must, &pb.Condition{
+			ConditionOneOf: &pb.Condition_Field{
+				Field: &pb.FieldCondition{Key: "ingested_at", Range: r},
+			},
+		})
+	}
+	return &pb.Filter{Must: must}
+}
+
+func keywordsCondition(field string, values []string) *pb.Condition {
+	return &pb.Condition{
+		ConditionOneOf: &pb.Condition_Field{
+			Field: &pb.FieldCondition{
+				Key: field,
+				Match: &pb.Match{
+					MatchValue: &pb.Match_Keywords{
+						Keywords: &pb.RepeatedStrings{Strings: values},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package embedstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	chunk := ChunkData{
+		Link:       "https://www.TED.com/talks/ocean",
+		Source:     SourceTED,
+		Session:    "s1",
+		Language:   "en",
+		IngestedAt: now,
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"source", Filter{Sources: []string{SourceWeb, SourceTED}}, true},
+		{"other source", Filter{Sources: []string{SourcePDF}}, false},
+		{"domain", Filter{Domains: []string{"ted.com"}}, true},
+		{"domain with www and case", Filter{Domains: []string{" WWW.Ted.com"}}, true},
+		{"other domain", Filter{Domains: []string{"ted.org"}}, false},
+		{"session", Filter{Sessions: []string{"s2"}}, false},
+		{"language", Filter{Languages: []string{"en"}}, true},
+		{"ingested after", Filter{IngestedAfter: now.Add(-time.Hour)}, true},
+		{"ingested before", Filter{IngestedBefore: now}, false},
+		{"all fields must match", Filter{Sources: []string{SourceTED}, Languages: []string{"de"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(chunk); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterQdrantDomains(t *testing.T) {
+	f := Filter{Domains: []string{"WWW.Example.com"}}
+	got := f.qdrantFilter().Must[0].GetField().GetMatch().GetKeywords().GetStrings()
+	if len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("qdrant domain condition = %q, want [example.com]", got)
+	}
+	if f.Domains[0] != "WWW.Example.com" {
+		t.Errorf("qdrantFilter modified the filter")
+	}
+}
@@ -0,0 +1,263 @@
+package embedstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+)
+
+// maxGeminiBatch is the most texts BatchEmbedContents accepts in one request.
+const maxGeminiBatch = 100
+
+// GeminiEmbedder embeds text with a Gemini embedding model. Texts are sent in
+// BatchEmbedContents requests of BatchSize, with at most Concurrency requests in
+// flight. Requests rejected with 429 or 5xx are retried with exponential backoff.
+//
+// Chunks are embedded as RETRIEVAL_DOCUMENT with their title, queries as
+// RETRIEVAL_QUERY and plain Embed calls (used by the semantic chunker) as
+// SEMANTIC_SIMILARITY.
+//
+// When Cache is set, only texts missing from it are sent to the API.
+type GeminiEmbedder struct {
+	Client      *genai.Client
+	Model       string
+	BatchSize   int
+	Concurrency int
+	MaxRetries  int
+	Backoff     time.Duration
+	Cache       *EmbeddingCache
+}
+
+func NewGeminiEmbedder(client *genai.Client, model string) *GeminiEmbedder {
+	return &GeminiEmbedder{
+		Client:      client,
+		Model:       model,
+		BatchSize:   maxGeminiBatch,
+		Concurrency: 4,
+		MaxRetries:  4,
+		Backoff:     500 * time.Millisecond,
+	}
+}
+
+func (e *GeminiEmbedder) Name() string {
+	return e.Model
+}
+
+// Embed returns one vector per text, in order. It fails as a whole if any batch does.
+func (e *GeminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embed(ctx, TaskSemanticSimilarity, texts, nil)
+}
+
+func (e *GeminiEmbedder) EmbedDocuments(ctx context.Context, chunks []ChunkData) ([][]float32, error) {
+	texts := make([]string, len(chunks))
+	titles := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i], titles[i] = chunk.Text, chunk.Title
+	}
+	return e.embed(ctx, TaskRetrievalDocument, texts, titles)
+}
+
+func (e *GeminiEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := e.embed(ctx, TaskRetrievalQuery, []string{query}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+var geminiTaskTypes = map[TaskType]genai.TaskType{
+	TaskRetrievalDocument:  genai.TaskTypeRetrievalDocument,
+	TaskRetrievalQuery:     genai.TaskTypeRetrievalQuery,
+	TaskSemanticSimilarity: genai.TaskTypeSemanticSimilarity,
+}
+
+// embed embeds texts for task, serving what it can from the cache. titles is nil or
+// holds one title per text and is only sent for RETRIEVAL_DOCUMENT, the only task type
+// the API accepts titles for.
+func (e *GeminiEmbedder) embed(ctx context.Context, task TaskType, texts, titles []string) ([][]float32, error) {
+	if task != TaskRetrievalDocument {
+		titles = nil
+	}
+	if e.Cache == nil {
+		return e.embedAll(ctx, task, texts, titles)
+	}
+
+	vectors := make([][]float32, len(texts))
+	keys := make([]CacheKey, len(texts))
+	var missing []int
+	var missingTexts, missingTitles []string
+	for i, text := range texts {
+		title := ""
+		if titles != nil {
+			title = titles[i]
+		}
+		keys[i] = NewCacheKey(e.Model, task, title, text)
+		if v, ok := e.Cache.Get(keys[i]); ok {
+			vectors[i] = v
+			continue
+		}
+		missing = append(missing, i)
+		missingTexts = append(missingTexts, text)
+		if titles != nil {
+			missingTitles = append(missingTitles, title)
+		}
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	fresh, err := e.embedAll(ctx, task, missingTexts, missingTitles)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missing {
+		vectors[i] = fresh[j]
+		if err := e.Cache.Put(keys[i], fresh[j]); err != nil {
+			log.Printf("failed to cache embedding: %v", err)
+		}
+	}
+	return vectors, nil
+}
+
+func (e *GeminiEmbedder) embedAll(ctx context.Context, task TaskType, texts, titles []string) ([][]float32, error) {
+	batchSize := e.BatchSize
+	if batchSize < 1 || batchSize > maxGeminiBatch {
+		batchSize = maxGeminiBatch
+	}
+	concurrency := e.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	em := e.Client.EmbeddingModel(e.Model)
+	em.TaskType = geminiTaskTypes[task]
+	vectors := make([][]float32, len(texts))
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var batchTitles []string
+			if titles != nil {
+				batchTitles = titles[start:end]
+			}
+			batch, err := e.embedBatch(ctx, em, texts[start:end], batchTitles)
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("failed to embed texts %d-%d: %w", start, end-1, err)
+					cancel()
+				})
+				return
+			}
+			copy(vectors[start:end], batch)
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+func (e *GeminiEmbedder) embedBatch(ctx context.Context, em *genai.EmbeddingModel, texts, titles []string) ([][]float32, error) {
+	batch := em.NewBatch()
+	for i, text := range texts {
+		if titles != nil {
+			batch.AddContentWithTitle(titles[i], genai.Text(text))
+		} else {
+			batch.AddContent(genai.Text(text))
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := em.BatchEmbedContents(ctx, batch)
+		if err == nil {
+			if len(res.Embeddings) != len(texts) {
+				return nil, fmt.Errorf("%s returned %d embeddings for %d texts", e.Model, len(res.Embeddings), len(texts))
+			}
+			vectors := make([][]float32, len(texts))
+			for i, emb := range res.Embeddings {
+				if emb == nil || len(emb.Values) == 0 {
+					return nil, fmt.Errorf("empty embedding returned by %s", e.Model)
+				}
+				vectors[i] = emb.Values
+			}
+			return vectors, nil
+		}
+
+		if attempt >= e.MaxRetries || !retryable(err) {
+			return nil, err
+		}
+		delay := backoff(e.Backoff, attempt)
+		log.Printf("embedding request failed, retrying in %s: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable reports whether err is a rate limit or server error.
+func retryable(err error) bool {
+	var apiErr *apierror.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if code := apiErr.HTTPCode(); code > 0 {
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	if st := apiErr.GRPCStatus(); st != nil {
+		switch st.Code() {
+		case codes.ResourceExhausted, codes.Unavailable, codes.Internal, codes.DeadlineExceeded:
+			return true
+		}
+	}
+	return false
+}
+
+// backoff doubles base for every attempt, caps it at 30s and adds up to 50% jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	d := base << attempt
+	if d > 30*time.Second || d <= 0 {
+		d = 30 * time.Second
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
@@ -0,0 +1,90 @@
+package embedstore
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"Audio-LLM-Contextual-Heygen/bm25"
+)
+
+// HashEmbedder is an offline Embedder built on feature hashing. Word unigrams and
+// bigrams and character trigrams of every word are hashed into Dimension buckets
+// with a hashed sign, and the result is L2-normalized. Vectors are deterministic and
+// texts that share vocabulary land close together, which is enough to exercise the
+// search -> store -> retrieve loop without network access. Task types are ignored.
+type HashEmbedder struct {
+	Dimension int
+}
+
+func NewHashEmbedder(dimension int) *HashEmbedder {
+	return &HashEmbedder{Dimension: dimension}
+}
+
+func (e *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-%d", e.Dimension)
+}
+
+func (e *HashEmbedder) EmbedDocuments(ctx context.Context, chunks []ChunkData) ([][]float32, error) {
+	vectors := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		vectors[i] = e.vector(chunk.Text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return e.vector(query), nil
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.vector(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) vector(text string) []float32 {
+	dim := e.Dimension
+	if dim < 1 {
+		dim = 768
+	}
+	v := make([]float32, dim)
+
+	words := bm25.Tokenize(text)
+	for i, word := range words {
+		e.add(v, "w:"+word, 1)
+		if i > 0 {
+			e.add(v, "b:"+words[i-1]+" "+word, 1)
+		}
+		runes := []rune("<" + word + ">")
+		for j := 0; j+3 <= len(runes); j++ {
+			e.add(v, "c:"+string(runes[j:j+3]), 0.5)
+		}
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range v {
+			v[i] *= scale
+		}
+	}
+	return v
+}
+
+func (e *HashEmbedder) add(v []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(feature)))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	v[sum%uint64(len(v))] += weight
+}
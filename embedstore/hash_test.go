@@ -0,0 +1,54 @@
+package embedstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestHashEmbedderRetrieval(t *testing.T) {
+	ctx := context.Background()
+	chunks := []ChunkData{
+		{Title: "Whales", Link: "https://example.com/whales", Text: "Blue whales are the largest animals ever known to have lived on Earth."},
+		{Title: "Coffee", Link: "https://example.com/coffee", Text: "Espresso is brewed by forcing hot water through finely ground coffee beans."},
+		{Title: "Rockets", Link: "https://example.com/rockets", Text: "A rocket engine burns propellant to produce thrust and reach orbit."},
+		{Title: "Bees", Link: "https://example.com/bees", Text: "Honey bees communicate the location of flowers with a waggle dance."},
+	}
+	queries := map[string]string{
+		"how large are blue whales":       "https://example.com/whales",
+		"how do you brew espresso coffee": "https://example.com/coffee",
+		"what does a rocket engine burn":  "https://example.com/rockets",
+		"why do bees dance":               "https://example.com/bees",
+	}
+
+	e := NewHashEmbedder(256)
+	vectors, err := e.EmbedDocuments(ctx, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := e.EmbedDocuments(ctx, chunks)
+	if !reflect.DeepEqual(vectors, again) {
+		t.Fatal("embeddings are not deterministic")
+	}
+
+	for name, s := range map[string]*MemoryStore{"exact": NewMemoryStore(), "hnsw": NewHNSWStore(HNSWConfig{})} {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Upsert(ctx, chunks, vectors); err != nil {
+				t.Fatal(err)
+			}
+			for query, want := range queries {
+				vector, err := e.EmbedQuery(ctx, query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				hits, err := s.Search(ctx, vector, 2, 0, Filter{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(hits) == 0 || hits[0].Link != want {
+					t.Errorf("%q: got %+v first, want %s", query, hits, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package embedstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSWConfig tunes the approximate index of a MemoryStore. Zero fields get the
+// defaults from the HNSW paper's recommendations: M 16, EfConstruction 200 and
+// EfSearch 64. Larger values trade speed for recall.
+type HNSWConfig struct {
+	// M is the number of links per node on the upper layers; layer 0 keeps 2*M.
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+func (c HNSWConfig) withDefaults() HNSWConfig {
+	if c.M < 2 {
+		c.M = 16
+	}
+	if c.EfConstruction < 1 {
+		c.EfConstruction = 200
+	}
+	if c.EfSearch < 1 {
+		c.EfSearch = 64
+	}
+	return c
+}
+
+// hnsw is a hierarchical navigable small world graph over unit vectors, scored by dot
+// product. Nodes are never removed from the graph, only marked deleted, so they keep
+// routing searches; the owner rebuilds the index when too many pile up.
+type hnsw struct {
+	cfg       HNSWConfig
+	levelMult float64
+	rng       *rand.Rand
+
+	nodes    []hnswNode
+	entry    int
+	maxLevel int
+	deleted  int
+}
+
+type hnswNode struct {
+	vector  []float32
+	links   [][]int
+	deleted bool
+}
+
+func newHNSW(cfg HNSWConfig) *hnsw {
+	cfg = cfg.withDefaults()
+	return &hnsw{
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		rng:       rand.New(rand.NewSource(1)),
+		entry:     -1,
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var s float32
+	b = b[:len(a)]
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// insert adds vector, which must be normalized, and returns its node number.
+func (h *hnsw) insert(vector []float32) int {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	id := len(h.nodes)
+	h.nodes = append(h.nodes, hnswNode{vector: vector, links: make([][]int, level+1)})
+	if h.entry < 0 {
+		h.entry, h.maxLevel = id, level
+		return id
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(vector, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, ep, h.cfg.EfConstruction, l)
+		neighbors := h.selectNeighbors(candidates, h.cfg.M)
+		h.nodes[id].links[l] = neighbors
+		for _, n := range neighbors {
+			h.link(n, id, l)
+		}
+		ep = candidates[0].node
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+	return id
+}
+
+func (h *hnsw) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.cfg.M
+	}
+	return h.cfg.M
+}
+
+// link adds a link from node to target, dropping node's weakest link when it has too many.
+func (h *hnsw) link(node, target, level int) {
+	links := append(h.nodes[node].links[level], target)
+	if len(links) > h.maxLinks(level) {
+		v := h.nodes[node].vector
+		scored := make([]hnswCandidate, len(links))
+		for i, n := range links {
+			scored[i] = hnswCandidate{node: n, score: dot(v, h.nodes[n].vector)}
+		}
+		sort.Slice(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+		links = links[:h.maxLinks(level)]
+		for i := range links {
+			links[i] = scored[i].node
+		}
+	}
+	h.nodes[node].links[level] = links
+}
+
+// selectNeighbors keeps up to m candidates that are closer to the new node than to
+// any neighbor already picked, which spreads links across clusters, then fills the
+// remaining slots with the closest leftovers.
+func (h *hnsw) selectNeighbors(candidates []hnswCandidate, m int) []int {
+	picked := make([]int, 0, m)
+	var skipped []int
+	for _, c := range candidates {
+		if len(picked) == m {
+			break
+		}
+		good := true
+		for _, p := range picked {
+			if dot(h.nodes[c.node].vector, h.nodes[p].vector) > c.score {
+				good = false
+				break
+			}
+		}
+		if good {
+			picked = append(picked, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(picked) == m {
+			break
+		}
+		picked = append(picked, n)
+	}
+	return picked
+}
+
+// greedy walks level from ep towards query and returns the closest node it finds.
+func (h *hnsw) greedy(query []float32, ep, level int) int {
+	best := dot(query, h.nodes[ep].vector)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.nodes[ep].links[level] {
+			if s := dot(query, h.nodes[n].vector); s > best {
+				best, ep, changed = s, n, true
+			}
+		}
+	}
+	return ep
+}
+
+type hnswCandidate struct {
+	node  int
+	score float32
+}
+
+// candidateHeap is a heap of candidates; worstFirst turns it into a min-heap by score.
+type candidateHeap struct {
+	items      []hnswCandidate
+	worstFirst bool
+}
+
+func (q *candidateHeap) Len() int { return len(q.items) }
+func (q *candidateHeap) Less(i, j int) bool {
+	if q.worstFirst {
+		return q.items[i].score < q.items[j].score
+	}
+	return q.items[i].score > q.items[j].score
+}
+func (q *candidateHeap) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *candidateHeap) Push(x any)    { q.items = append(q.items, x.(hnswCandidate)) }
+func (q *candidateHeap) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+// searchLayer returns the ef nodes on level closest to query, best first, including
+// deleted ones.
+func (h *hnsw) searchLayer(query []float32, ep, ef, level int) []hnswCandidate {
+	start := hnswCandidate{node: ep, score: dot(query, h.nodes[ep].vector)}
+	visited := make([]bool, len(h.nodes))
+	visited[ep] = true
+	candidates := &candidateHeap{items: []hnswCandidate{start}}
+	results := &candidateHeap{items: []hnswCandidate{start}, worstFirst: true}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.score < results.items[0].score {
+			break
+		}
+		for _, n := range h.nodes[c.node].links[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			s := dot(query, h.nodes[n].vector)
+			if results.Len() < ef || s > results.items[0].score {
+				heap.Push(candidates, hnswCandidate{node: n, score: s})
+				heap.Push(results, hnswCandidate{node: n, score: s})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sort.Slice(results.items, func(i, j int) bool { return results.items[i].score > results.items[j].score })
+	return results.items
+}
+
+// search returns up to ef live nodes closest to query, best first. Deleted nodes
+// still take up places in the beam, so ef is raised by the deleted fraction to keep
+// about ef live ones.
+func (h *hnsw) search(query []float32, ef int) []hnswCandidate {
+	live := len(h.nodes) - h.deleted
+	if h.entry < 0 || live == 0 {
+		return nil
+	}
+	ef = max(ef, h.cfg.EfSearch)
+	ef = (ef*len(h.nodes) + live - 1) / live
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+	found := h.searchLayer(query, ep, ef, 0)
+	results := found[:0]
+	for _, c := range found {
+		if !h.nodes[c.node].deleted {
+			results = append(results, c)
+		}
+	}
+	return results
+}
+
+func (h *hnsw) remove(node int) {
+	if !h.nodes[node].deleted {
+		h.nodes[node].deleted = true
+		h.deleted++
+	}
+}
@@ -0,0 +1,71 @@
+package embedstore
+
+import (
+	"sync"
+
+	"Audio-LLM-Contextual-Heygen/bm25"
+)
+
+// KeywordIndex is the sparse half of hybrid retrieval: a BM25 index over the same
+// chunks as a vector store, keyed by PointID so both sides agree on identity. It
+// catches exact terms such as names and product codes that embeddings blur.
+type KeywordIndex struct {
+	mu     sync.RWMutex
+	index  *bm25.Index
+	chunks map[string]ChunkData
+}
+
+func NewKeywordIndex() *KeywordIndex {
+	return &KeywordIndex{index: bm25.New(), chunks: make(map[string]ChunkData)}
+}
+
+// Add indexes chunks, replacing earlier versions of the same chunks.
+func (k *KeywordIndex) Add(chunks []ChunkData) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, chunk := range chunks {
+		id := PointID(chunk)
+		chunk.Score, chunk.Vector = 0, nil
+		k.chunks[id] = chunk
+		k.index.Add(id, chunk.Title+"\n"+chunk.Text)
+	}
+}
+
+// Delete drops the chunks matching filter; an empty filter drops everything.
+func (k *KeywordIndex) Delete(filter Filter) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for id, chunk := range k.chunks {
+		if filter.Match(chunk) {
+			k.index.Remove(id)
+			delete(k.chunks, id)
+		}
+	}
+}
+
+func (k *KeywordIndex) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.chunks)
+}
+
+// Search returns up to limit chunks matching filter ranked by BM25, with Score set
+// to the BM25 score.
+func (k *KeywordIndex) Search(query string, limit int, filter Filter) []ChunkData {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var chunks []ChunkData
+	for _, hit := range k.index.Search(query, 0) {
+		if len(chunks) == limit {
+			break
+		}
+		chunk, ok := k.chunks[hit.ID]
+		if !ok || !filter.Match(chunk) {
+			continue
+		}
+		chunk.Score = float32(hit.Score)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
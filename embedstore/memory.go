@@ -0,0 +1,348 @@
+package embedstore
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is an in-process VectorStore with the same behaviour as QdrantStore:
+// point IDs come from PointID, scores are cosine similarities and Search returns the
+// best chunks first. By default it scans every vector; NewHNSWStore builds an
+// approximate index instead, which is worth it from tens of thousands of chunks on.
+//
+// Nothing is persisted unless Save is called; LoadMemoryStore restores a snapshot.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	hnswCfg   *HNSWConfig
+	index     *hnsw
+	points    map[string]*memoryPoint
+	nodes     []string // HNSW node -> point ID
+	dimension int
+	version   uint64
+}
+
+type memoryPoint struct {
+	chunk  ChunkData
+	vector []float32
+	node   int
+}
+
+// NewMemoryStore returns an empty store that answers searches exactly.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{points: make(map[string]*memoryPoint)}
+}
+
+// NewHNSWStore returns an empty store that answers searches from an HNSW index.
+func NewHNSWStore(cfg HNSWConfig) *MemoryStore {
+	cfg = cfg.withDefaults()
+	s := NewMemoryStore()
+	s.hnswCfg = &cfg
+	s.index = newHNSW(cfg)
+	return s
+}
+
+// Len returns the number of stored chunks.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.points)
+}
+
+// Version changes whenever the store is modified, so callers can tell whether a
+// snapshot is stale.
+func (s *MemoryStore) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, x := range vector {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(vector))
+	if norm == 0 {
+		return out
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, x := range vector {
+		out[i] = x * scale
+	}
+	return out
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, chunks []ChunkData, vectors [][]float32) error {
+	if len(chunks) != len(vectors) {
+		return fmt.Errorf("got %d chunks but %d vectors", len(chunks), len(vectors))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every vector first so a bad batch leaves the store untouched.
+	dimension := s.dimension
+	for i, vector := range vectors {
+		if dimension == 0 {
+			dimension = len(vector)
+		}
+		if len(vector) != dimension {
+			return fmt.Errorf("chunk %d has a %d-dimensional vector, store holds %d-dimensional ones", i, len(vector), dimension)
+		}
+	}
+	s.dimension = dimension
+	for i, chunk := range chunks {
+		s.put(PointID(chunk), chunk, normalize(vectors[i]))
+	}
+	s.compact()
+	s.version++
+	return nil
+}
+
+// put stores a normalized vector under id, replacing any previous point. A point
+// whose vector is unchanged, as on re-ingest, keeps its HNSW node and only gets the
+// new payload. It must be called with s.mu held.
+func (s *MemoryStore) put(id string, chunk ChunkData, vector []float32) {
+	chunk.Score, chunk.Vector = 0, nil
+	old, ok := s.points[id]
+	if ok && slices.Equal(old.vector, vector) {
+		s.points[id] = &memoryPoint{chunk: chunk, vector: old.vector, node: old.node}
+		return
+	}
+	p := &memoryPoint{chunk: chunk, vector: vector, node: -1}
+	if ok && s.index != nil {
+		s.index.remove(old.node)
+	}
+	if s.index != nil {
+		p.node = s.index.insert(vector)
+		s.nodes = append(s.nodes, id)
+	}
+	s.points[id] = p
+}
+
+// Search returns up to limit chunks matching filter whose cosine similarity to vector
+// is at least scoreThreshold, best first. Hits carry their normalized vector, which
+// is shared with the store and must not be modified. In HNSW mode a selective filter can leave
+// too few matches among the approximate neighbours; the search then falls back to
+// scanning the matching chunks.
+func (s *MemoryStore) Search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter Filter) ([]ChunkData, error) {
+	if limit < 1 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.points) == 0 {
+		return nil, nil
+	}
+	if len(vector) != s.dimension {
+		return nil, fmt.Errorf("query vector has %d dimensions, store holds %d", len(vector), s.dimension)
+	}
+
+	query := normalize(vector)
+	var chunks []ChunkData
+	if s.index != nil {
+		exhausted := false
+		for _, c := range s.index.search(query, limit) {
+			if c.score < scoreThreshold || len(chunks) == limit {
+				exhausted = true
+				break
+			}
+			chunk := s.points[s.nodes[c.node]].chunk
+			if !filter.Match(chunk) {
+				continue
+			}
+			chunk.Score, chunk.Vector = c.score, s.points[s.nodes[c.node]].vector
+			chunks = append(chunks, chunk)
+		}
+		if exhausted || len(chunks) == limit || filter.IsEmpty() {
+			return chunks, nil
+		}
+		chunks = nil
+	}
+
+	type scored struct {
+		point *memoryPoint
+		score float32
+	}
+	var hits []scored
+	for _, p := range s.points {
+		if !filter.Match(p.chunk) {
+			continue
+		}
+		if score := dot(query, p.vector); score >= scoreThreshold {
+			hits = append(hits, scored{p, score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.point.chunk.Link < b.point.chunk.Link || a.point.chunk.Link == b.point.chunk.Link && a.point.chunk.Index < b.point.chunk.Index
+	})
+	for _, hit := range hits[:min(limit, len(hits))] {
+		chunk := hit.point.chunk
+		chunk.Score, chunk.Vector = hit.score, hit.point.vector
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Scroll calls fn for every stored chunk, in ID order.
+func (s *MemoryStore) Scroll(ctx context.Context, fn func(ChunkData)) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range sortedIDs(s.points) {
+		fn(s.points[id].chunk)
+	}
+	return nil
+}
+
+// Get returns the chunks stored under ids, skipping unknown IDs.
+func (s *MemoryStore) Get(ctx context.Context, ids []string) ([]ChunkData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var chunks []ChunkData
+	for _, id := range ids {
+		if p, ok := s.points[id]; ok {
+			chunks = append(chunks, p.chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// Delete removes every chunk matching filter. An empty filter is refused; use Reset
+// to empty the store.
+func (s *MemoryStore) Delete(ctx context.Context, filter Filter) error {
+	if filter.IsEmpty() {
+		return fmt.Errorf("refusing to delete with an empty filter")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, p := range s.points {
+		if filter.Match(p.chunk) {
+			if s.index != nil {
+				s.index.remove(p.node)
+			}
+			delete(s.points, id)
+		}
+	}
+	s.compact()
+	s.version++
+	return nil
+}
+
+// Reset removes every chunk.
+func (s *MemoryStore) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = make(map[string]*memoryPoint)
+	s.dimension = 0
+	if s.index != nil {
+		s.index = newHNSW(*s.hnswCfg)
+		s.nodes = nil
+	}
+	s.version++
+}
+
+// compact rebuilds the HNSW index once deleted and replaced nodes, which still cost
+// search time, make up half of it. It must be called with s.mu held.
+func (s *MemoryStore) compact() {
+	if s.index == nil || s.index.deleted <= len(s.index.nodes)/2 {
+		return
+	}
+	points := s.points
+	s.points = make(map[string]*memoryPoint, len(points))
+	s.index = newHNSW(*s.hnswCfg)
+	s.nodes = nil
+	for _, id := range sortedIDs(points) {
+		s.put(id, points[id].chunk, points[id].vector)
+	}
+}
+
+func sortedIDs(points map[string]*memoryPoint) []string {
+	ids := make([]string, 0, len(points))
+	for id := range points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// memorySnapshot is the gob-encoded file written by Save. The HNSW graph is not
+// stored; it is rebuilt on load.
+type memorySnapshot struct {
+	HNSW   *HNSWConfig
+	Points []snapshotPoint
+}
+
+type snapshotPoint struct {
+	ID     string
+	Chunk  ChunkData
+	Vector []float32
+}
+
+// Save writes the store to path. The file is replaced atomically, so a crash never
+// leaves a half-written snapshot behind.
+func (s *MemoryStore) Save(path string) error {
+	s.mu.RLock()
+	snapshot := memorySnapshot{HNSW: s.hnswCfg, Points: make([]snapshotPoint, 0, len(s.points))}
+	for _, id := range sortedIDs(s.points) {
+		p := s.points[id]
+		snapshot.Points = append(snapshot.Points, snapshotPoint{ID: id, Chunk: p.chunk, Vector: p.vector})
+	}
+	s.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(snapshot); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadMemoryStore restores a store saved with Save, in the search mode it was saved with.
+func LoadMemoryStore(path string) (*MemoryStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error reading snapshot %s: %w", path, err)
+	}
+
+	s := NewMemoryStore()
+	if snapshot.HNSW != nil {
+		s = NewHNSWStore(*snapshot.HNSW)
+	}
+	for _, p := range snapshot.Points {
+		if s.dimension == 0 {
+			s.dimension = len(p.Vector)
+		}
+		if len(p.Vector) != s.dimension {
+			return nil, fmt.Errorf("snapshot %s mixes %d- and %d-dimensional vectors", path, s.dimension, len(p.Vector))
+		}
+		s.put(p.ID, p.Chunk, p.Vector)
+	}
+	return s, nil
+}
@@ -0,0 +1,253 @@
+package embedstore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMemoryStoreUpsertIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	chunk := func(i int) ChunkData {
+		return ChunkData{Title: "Doc", Link: "https://example.com", Text: fmt.Sprint("chunk ", i), Index: i}
+	}
+	stores := map[string]func() *MemoryStore{
+		"exact": NewMemoryStore,
+		"hnsw":  func() *MemoryStore { return NewHNSWStore(HNSWConfig{}) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			// A mixed batch on an empty store must not fix the dimension.
+			err := s.Upsert(ctx, []ChunkData{chunk(0), chunk(1)}, [][]float32{{1, 0}, {1, 0, 0}})
+			if err == nil {
+				t.Fatal("mixed dimensions accepted")
+			}
+			if s.Len() != 0 || s.Version() != 0 {
+				t.Fatalf("failed batch left %d chunks, version %d", s.Len(), s.Version())
+			}
+
+			if err := s.Upsert(ctx, []ChunkData{chunk(0)}, [][]float32{{1, 0, 0}}); err != nil {
+				t.Fatal(err)
+			}
+			version := s.Version()
+			err = s.Upsert(ctx, []ChunkData{chunk(1), chunk(2)}, [][]float32{{0, 1, 0}, {0, 1}})
+			if err == nil {
+				t.Fatal("wrong dimension accepted")
+			}
+			if s.Len() != 1 || s.Version() != version {
+				t.Fatalf("failed batch left %d chunks, version %d -> %d", s.Len(), version, s.Version())
+			}
+			hits, err := s.Search(ctx, []float32{0, 1, 0}, 10, 0.5, Filter{})
+			if err != nil || len(hits) != 0 {
+				t.Fatalf("Search after failed batch = %v, %v", hits, err)
+			}
+		})
+	}
+}
+
+// randomPoints returns n chunks spread over a few sources with random vectors.
+func randomPoints(r *rand.Rand, n, dimension int) ([]ChunkData, [][]float32) {
+	chunks := make([]ChunkData, n)
+	vectors := make([][]float32, n)
+	for i := range chunks {
+		chunks[i] = ChunkData{Title: "Doc", Link: fmt.Sprintf("https://example.com/%d", i/10), Text: fmt.Sprint("chunk ", i), Index: i % 10, Source: fmt.Sprint("source", i%5)}
+		vectors[i] = make([]float32, dimension)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(r.NormFloat64())
+		}
+	}
+	return chunks, vectors
+}
+
+func searchIDs(t *testing.T, s *MemoryStore, query []float32, limit int, filter Filter) []string {
+	t.Helper()
+	hits, err := s.Search(context.Background(), query, limit, -1, filter)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = PointID(hit)
+	}
+	return ids
+}
+
+func TestHNSWRecall(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+	chunks, vectors := randomPoints(r, 2000, 24)
+	exact, approx := NewMemoryStore(), NewHNSWStore(HNSWConfig{})
+	for _, s := range []*MemoryStore{exact, approx} {
+		if err := s.Upsert(ctx, chunks, vectors); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recall := func() float64 {
+		found, total := 0, 0
+		for q := 0; q < 50; q++ {
+			query := make([]float32, 24)
+			for j := range query {
+				query[j] = float32(r.NormFloat64())
+			}
+			want := searchIDs(t, exact, query, 10, Filter{})
+			got := searchIDs(t, approx, query, 10, Filter{})
+			for _, id := range want {
+				if slices.Contains(got, id) {
+					found++
+				}
+			}
+			total += len(want)
+		}
+		return float64(found) / float64(total)
+	}
+	if got := recall(); got < 0.95 {
+		t.Errorf("recall@10 = %.3f, want at least 0.95", got)
+	}
+
+	// Deleting two sources leaves 40% of the graph as tombstones, short of a rebuild.
+	for _, s := range []*MemoryStore{exact, approx} {
+		if err := s.Delete(ctx, Filter{Sources: []string{"source0", "source1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if approx.index.deleted == 0 {
+		t.Fatal("delete rebuilt the index; the test needs tombstones")
+	}
+	if got := recall(); got < 0.95 {
+		t.Errorf("recall@10 after deletes = %.3f, want at least 0.95", got)
+	}
+}
+
+func TestMemoryStoreReupsertKeepsNode(t *testing.T) {
+	ctx := context.Background()
+	s := NewHNSWStore(HNSWConfig{})
+	chunk := ChunkData{Title: "Old title", Link: "https://example.com", Text: "text"}
+	if err := s.Upsert(ctx, []ChunkData{chunk}, [][]float32{{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-ingesting the same text keeps the point ID and vector but updates the payload.
+	chunk.Title = "New title"
+	if err := s.Upsert(ctx, []ChunkData{chunk}, [][]float32{{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.index.nodes) != 1 || s.index.deleted != 0 {
+		t.Errorf("same vector: index has %d nodes, %d deleted; want 1, 0", len(s.index.nodes), s.index.deleted)
+	}
+	hits, err := s.Search(ctx, []float32{1, 2, 3}, 1, 0, Filter{})
+	if err != nil || len(hits) != 1 || hits[0].Title != "New title" {
+		t.Fatalf("Search = %+v, %v; want the new payload", hits, err)
+	}
+
+	if err := s.Upsert(ctx, []ChunkData{chunk}, [][]float32{{3, 2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.index.nodes) != 2 || s.index.deleted != 1 {
+		t.Errorf("new vector: index has %d nodes, %d deleted; want 2, 1", len(s.index.nodes), s.index.deleted)
+	}
+}
+
+func TestMemoryStoreSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	chunks, vectors := randomPoints(rand.New(rand.NewSource(2)), 200, 8)
+	stores := map[string]*MemoryStore{
+		"exact": NewMemoryStore(),
+		"hnsw":  NewHNSWStore(HNSWConfig{M: 8, EfSearch: 32}),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Upsert(ctx, chunks, vectors); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "store.gob")
+			if err := s.Save(path); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			loaded, err := LoadMemoryStore(path)
+			if err != nil {
+				t.Fatalf("LoadMemoryStore: %v", err)
+			}
+
+			if loaded.Len() != s.Len() {
+				t.Errorf("loaded %d chunks, saved %d", loaded.Len(), s.Len())
+			}
+			if !reflect.DeepEqual(loaded.hnswCfg, s.hnswCfg) {
+				t.Errorf("loaded HNSW config %+v, saved %+v", loaded.hnswCfg, s.hnswCfg)
+			}
+			for _, query := range vectors[:20] {
+				want := searchIDs(t, s, query, 5, Filter{})
+				if got := searchIDs(t, loaded, query, 5, Filter{}); !reflect.DeepEqual(got, want) {
+					t.Errorf("loaded store found %v, original %v", got, want)
+				}
+			}
+			got, _ := loaded.Get(ctx, []string{PointID(chunks[7])})
+			if len(got) != 1 || got[0].Text != chunks[7].Text {
+				t.Errorf("Get after load = %+v", got)
+			}
+		})
+	}
+
+	if _, err := LoadMemoryStore(filepath.Join(t.TempDir(), "missing.gob")); err == nil {
+		t.Error("loading a missing snapshot succeeded")
+	}
+}
+
+func TestMemoryStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	chunks, vectors := randomPoints(rand.New(rand.NewSource(3)), 50, 4)
+	s := NewHNSWStore(HNSWConfig{})
+	if err := s.Upsert(ctx, chunks, vectors); err != nil {
+		t.Fatal(err)
+	}
+	version := s.Version()
+
+	if err := s.Delete(ctx, Filter{}); err == nil {
+		t.Fatal("Delete with an empty filter succeeded")
+	}
+	if s.Len() != 50 || s.Version() != version {
+		t.Fatalf("refused delete left %d chunks, version %d -> %d", s.Len(), version, s.Version())
+	}
+
+	if err := s.Delete(ctx, Filter{Sources: []string{"source2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 40 || s.Version() == version {
+		t.Errorf("after delete: %d chunks, version %d; want 40 and a new version", s.Len(), s.Version())
+	}
+	s.Scroll(ctx, func(chunk ChunkData) {
+		if chunk.Source == "source2" {
+			t.Errorf("deleted chunk %q still stored", chunk.Text)
+		}
+	})
+	for _, id := range searchIDs(t, s, vectors[2], 50, Filter{}) {
+		if got, _ := s.Get(ctx, []string{id}); len(got) != 1 || got[0].Source == "source2" {
+			t.Errorf("Search returned deleted chunk %s", id)
+		}
+	}
+}
+
+func TestHNSWSelectiveFilterFallsBack(t *testing.T) {
+	ctx := context.Background()
+	chunks, vectors := randomPoints(rand.New(rand.NewSource(4)), 500, 8)
+	// One chunk in its own session, pointing away from the query.
+	chunks[123].Session = "rare"
+	query := make([]float32, 8)
+	for j := range query {
+		query[j] = -vectors[123][j]
+	}
+
+	s := NewHNSWStore(HNSWConfig{EfSearch: 10})
+	if err := s.Upsert(ctx, chunks, vectors); err != nil {
+		t.Fatal(err)
+	}
+	got := searchIDs(t, s, query, 3, Filter{Sessions: []string{"rare"}})
+	if want := []string{PointID(chunks[123])}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered search = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,399 @@
+package embedstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// QdrantConfig says where and how to reach Qdrant's gRPC API.
+type QdrantConfig struct {
+	// Addr is host:port of the gRPC endpoint; empty means localhost:6334.
+	Addr   string
+	TLS    bool
+	APIKey string
+	// Timeout bounds every call whose context has no earlier deadline.
+	Timeout time.Duration
+}
+
+// QdrantStore is the pipeline's VectorStore backed by Qdrant. It holds one
+// long-lived gRPC connection; WithCollection returns stores for other collections
+// that share it. Collection defaults to DefaultCollection.
+type QdrantStore struct {
+	Collection string
+	// BatchSize is the number of points sent per upsert request.
+	BatchSize int
+
+	conn        *grpc.ClientConn
+	points      pb.PointsClient
+	collections pb.CollectionsClient
+	timeout     time.Duration
+}
+
+// NewQdrantStore connects to Qdrant. The connection is established lazily, so an
+// unreachable server surfaces as errors from the store's methods, not here.
+func NewQdrantStore(cfg QdrantConfig) (*QdrantStore, error) {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = "localhost:6334"
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.TLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if cfg.APIKey != "" {
+		if !cfg.TLS {
+			log.Println("Warning: sending the Qdrant API key without TLS")
+		}
+		opts = append(opts, grpc.WithUnaryInterceptor(apiKeyInterceptor(cfg.APIKey)))
+	}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("did not connect to Qdrant at %s: %w", addr, err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &QdrantStore{
+		BatchSize:   64,
+		conn:        conn,
+		points:      pb.NewPointsClient(conn),
+		collections: pb.NewCollectionsClient(conn),
+		timeout:     timeout,
+	}, nil
+}
+
+func apiKeyInterceptor(key string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, "api-key", key), method, req, reply, cc, opts...)
+	}
+}
+
+// WithCollection returns a store for collection sharing s's connection.
+func (s *QdrantStore) WithCollection(collection string) *QdrantStore {
+	c := *s
+	c.Collection = collection
+	return &c
+}
+
+// Close closes the shared connection, for every store derived from it.
+func (s *QdrantStore) Close() error {
+	return s.conn.Close()
+}
+
+func (s *QdrantStore) collection() string {
+	if s.Collection == "" {
+		return DefaultCollection
+	}
+	return s.Collection
+}
+
+func (s *QdrantStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// Upsert stores chunks in batches of BatchSize points. Point IDs come from PointID, so
+// upserting the same chunks again overwrites them instead of adding duplicates.
+func (s *QdrantStore) Upsert(ctx context.Context, chunks []ChunkData, vectors [][]float32) error {
+	if len(chunks) != len(vectors) {
+		return fmt.Errorf("got %d chunks but %d vectors", len(chunks), len(vectors))
+	}
+	batchSize := s.BatchSize
+	if batchSize < 1 {
+		batchSize = len(chunks)
+	}
+
+	for start := 0; start < len(chunks); start += batchSize {
+		end := min(start+batchSize, len(chunks))
+		points := make([]*pb.PointStruct, 0, end-start)
+		for i := start; i < end; i++ {
+			points = append(points, &pb.PointStruct{
+				Id: &pb.PointId{
+					PointIdOptions: &pb.PointId_Uuid{
+						Uuid: PointID(chunks[i]),
+					},
+				},
+				Vectors: &pb.Vectors{
+					VectorsOptions: &pb.Vectors_Vector{
+						Vector: &pb.Vector{
+							Data: vectors[i],
+						},
+					},
+				},
+				Payload: chunkPayload(chunks[i]),
+			})
+		}
+		if err := s.upsert(ctx, points); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *QdrantStore) upsert(ctx context.Context, points []*pb.PointStruct) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	wait := true
+	_, err := s.points.Upsert(ctx, &pb.UpsertPoints{
+		CollectionName: s.collection(),
+		Wait:           &wait,
+		Points:         points,
+	})
+	if err != nil {
+		return fmt.Errorf("could not upsert %d points: %w", len(points), err)
+	}
+	return nil
+}
+
+// PointID derives a stable UUIDv5 for a chunk from its link, its index within the
+// document and a hash of its text.
+func PointID(chunk ChunkData) string {
+	sum := sha256.Sum256([]byte(chunk.Text))
+	name := fmt.Sprintf("%s#%d#%s", chunk.Link, chunk.Index, hex.EncodeToString(sum[:]))
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
+
+// Search returns the chunks matching filter closest to vector, best first, with their
+// scores. The payload comes back with the hits, so this is a single request.
+func (s *QdrantStore) Search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter Filter) ([]ChunkData, error) {
+	return s.search(ctx, vector, limit, scoreThreshold, filter, false)
+}
+
+// SearchWithVectors is Search with each hit's stored vector in Vector, which costs a
+// dimension's worth of floats per hit on the wire.
+func (s *QdrantStore) SearchWithVectors(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter Filter) ([]ChunkData, error) {
+	return s.search(ctx, vector, limit, scoreThreshold, filter, true)
+}
+
+func (s *QdrantStore) search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter Filter, withVectors bool) ([]ChunkData, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	searchResult, err := s.points.Search(ctx, &pb.SearchPoints{
+		CollectionName: s.collection(),
+		Vector:         vector,
+		Filter:         filter.qdrantFilter(),
+		Limit:          uint64(limit),
+		WithPayload: &pb.WithPayloadSelector{
+			SelectorOptions: &pb.WithPayloadSelector_Enable{
+				Enable: true,
+			},
+		},
+		WithVectors: &pb.WithVectorsSelector{
+			SelectorOptions: &pb.WithVectorsSelector_Enable{
+				Enable: withVectors,
+			},
+		},
+		ScoreThreshold: &scoreThreshold,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Qdrant: %w", err)
+	}
+
+	chunks := make([]ChunkData, 0, len(searchResult.Result))
+	for _, point := range searchResult.Result {
+		chunk, ok := chunkFromPayload(point.Payload)
+		if !ok {
+			log.Printf("skipping noisy or empty chunk ID %s", point.Id.GetUuid())
+			continue
+		}
+		chunk.Score = point.Score
+		chunk.Vector = point.Vectors.GetVector().GetData()
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Get fetches the chunks stored under ids in one request. IDs that do not exist are
+// left out, so the result can be shorter than ids and is not in any particular order.
+func (s *QdrantStore) Get(ctx context.Context, ids []string) ([]ChunkData, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	pointIDs := make([]*pb.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = &pb.PointId{
+			PointIdOptions: &pb.PointId_Uuid{
+				Uuid: id,
+			},
+		}
+	}
+	response, err := s.points.Get(ctx, &pb.GetPoints{
+		CollectionName: s.collection(),
+		Ids:            pointIDs,
+		WithPayload: &pb.WithPayloadSelector{
+			SelectorOptions: &pb.WithPayloadSelector_Enable{
+				Enable: true,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %d chunks: %w", len(ids), err)
+	}
+
+	chunks := make([]ChunkData, 0, len(response.Result))
+	for _, point := range response.Result {
+		chunk, ok := chunkFromPayload(point.Payload)
+		if !ok {
+			log.Printf("skipping noisy or empty chunk ID %s", point.Id.GetUuid())
+			continue
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Scroll calls fn for every stored chunk, a page at a time.
+func (s *QdrantStore) Scroll(ctx context.Context, fn func(ChunkData)) error {
+	limit := uint32(256)
+	var offset *pb.PointId
+	for {
+		page, err := s.scrollPage(ctx, offset, limit)
+		if err != nil {
+			return err
+		}
+		for _, point := range page.Result {
+			if chunk, ok := chunkFromPayload(point.Payload); ok {
+				fn(chunk)
+			}
+		}
+		offset = page.NextPageOffset
+		if offset == nil {
+			return nil
+		}
+	}
+}
+
+func (s *QdrantStore) scrollPage(ctx context.Context, offset *pb.PointId, limit uint32) (*pb.ScrollResponse, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	page, err := s.points.Scroll(ctx, &pb.ScrollPoints{
+		CollectionName: s.collection(),
+		Offset:         offset,
+		Limit:          &limit,
+		WithPayload: &pb.WithPayloadSelector{
+			SelectorOptions: &pb.WithPayloadSelector_Enable{
+				Enable: true,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scroll collection %s: %w", s.collection(), err)
+	}
+	return page, nil
+}
+
+// Delete removes every chunk matching filter. An empty filter is refused; use
+// ResetCollection to wipe a collection.
+func (s *QdrantStore) Delete(ctx context.Context, filter Filter) error {
+	if filter.IsEmpty() {
+		return fmt.Errorf("refusing to delete with an empty filter")
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	wait := true
+	_, err := s.points.Delete(ctx, &pb.DeletePoints{
+		CollectionName: s.collection(),
+		Wait:           &wait,
+		Points: &pb.PointsSelector{
+			PointsSelectorOneOf: &pb.PointsSelector_Filter{
+				Filter: filter.qdrantFilter(),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete points: %w", err)
+	}
+	return nil
+}
+
+func chunkPayload(chunk ChunkData) map[string]*pb.Value {
+	payload := map[string]*pb.Value{
+		"title":  {Kind: &pb.Value_StringValue{StringValue: chunk.Title}},
+		"link":   {Kind: &pb.Value_StringValue{StringValue: chunk.Link}},
+		"text":   {Kind: &pb.Value_StringValue{StringValue: chunk.Text}},
+		"source": {Kind: &pb.Value_StringValue{StringValue: chunk.Source}},
+	}
+	if chunk.Model != "" {
+		payload["model"] = &pb.Value{Kind: &pb.Value_StringValue{StringValue: chunk.Model}}
+	}
+	if chunk.TaskType != "" {
+		payload["task_type"] = &pb.Value{Kind: &pb.Value_StringValue{StringValue: string(chunk.TaskType)}}
+	}
+	payload["chunk_index"] = &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: int64(chunk.Index)}}
+	if domain := chunk.Domain(); domain != "" {
+		payload["domain"] = &pb.Value{Kind: &pb.Value_StringValue{StringValue: domain}}
+	}
+	if chunk.Language != "" {
+		payload["language"] = &pb.Value{Kind: &pb.Value_StringValue{StringValue: chunk.Language}}
+	}
+	if chunk.Session != "" {
+		payload["session"] = &pb.Value{Kind: &pb.Value_StringValue{StringValue: chunk.Session}}
+	}
+	if !chunk.IngestedAt.IsZero() {
+		payload["ingested_at"] = &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: chunk.IngestedAt.Unix()}}
+	}
+	if chunk.Page > 0 {
+		payload["page"] = &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: int64(chunk.Page)}}
+	}
+	if chunk.End > 0 {
+		payload["start"] = &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: chunk.Start.Seconds()}}
+		payload["end"] = &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: chunk.End.Seconds()}}
+	}
+	return payload
+}
+
+// chunkFromPayload rebuilds a chunk from its payload. It reports false for payloads
+// without text and for text that looks like leaked markup or JSON.
+func chunkFromPayload(payload map[string]*pb.Value) (ChunkData, bool) {
+	text, ok := payload["text"]
+	if !ok {
+		return ChunkData{}, false
+	}
+	textContent := text.GetStringValue()
+	if strings.Contains(textContent, "::") || strings.Contains(textContent, "{") || strings.Contains(textContent, "}") {
+		return ChunkData{}, false
+	}
+	chunk := ChunkData{
+		Title:  payload["title"].GetStringValue(),
+		Link:   payload["link"].GetStringValue(),
+		Text:   textContent,
+		Source: payload["source"].GetStringValue(),
+		Index:  int(payload["chunk_index"].GetIntegerValue()),
+		Page:   int(payload["page"].GetIntegerValue()),
+		Start:  time.Duration(payload["start"].GetDoubleValue() * float64(time.Second)),
+		End:    time.Duration(payload["end"].GetDoubleValue() * float64(time.Second)),
+
+		Model:    payload["model"].GetStringValue(),
+		TaskType: TaskType(payload["task_type"].GetStringValue()),
+		Language: payload["language"].GetStringValue(),
+		Session:  payload["session"].GetStringValue(),
+	}
+	if ingested, ok := payload["ingested_at"]; ok {
+		chunk.IngestedAt = time.Unix(ingested.GetIntegerValue(), 0).UTC()
+	}
+	return chunk, true
+}
@@ -2,108 +2,23 @@ package extract
 
 import (
 	"Audio-LLM-Contextual-Heygen/embedstore"
+	"Audio-LLM-Contextual-Heygen/subtitle"
+	"bytes"
 	"context"
-	"fmt"
 	"log"
 	"math"
-	"net/http"
 	"regexp"
 	"strings"
-	"time"
-
-	"github.com/tmc/langchaingo/schema"
-
-	"encoding/base64"
 
 	"github.com/PuerkitoBio/goquery"
-	readability "github.com/go-shiori/go-readability"
-	"github.com/redis/go-redis/v9"
 	"github.com/texttheater/golang-levenshtein/levenshtein"
 )
 
 const (
-	googleSearchURL   = "https://www.googleapis.com/customsearch/v1"
-	geminiAPIURL      = "https://api.gemini.com/v1/embedding"
-	RedisAddr         = "localhost:6379"
-	RedisPassword     = ""
-	RedisDB           = 0
-	EmbeddingCacheKey = "embedding_cache"
-	CacheSize         = 5
+	googleSearchURL = "https://www.googleapis.com/customsearch/v1"
+	geminiAPIURL    = "https://api.gemini.com/v1/embedding"
 )
 
-func init() {
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:     RedisAddr,
-		Password: RedisPassword,
-		DB:       RedisDB,
-	})
-}
-
-func CacheEmbedding(embedding []float32) error {
-
-	ctx := context.Background()
-	// Redis sorted set --> store embeddings with timestamps
-	member := &redis.Z{
-		Score:  float64(time.Now().UnixNano()),
-		Member: base64.StdEncoding.EncodeToString(embedding),
-	}
-
-	err = redisClient.ZAdd(ctx, EmbeddingCacheKey, *member).Err()
-	if err != nil {
-		return fmt.Errorf("failed to add embedding to cache: %w", err)
-	}
-
-	err = redisClient.ZRemRangeByRank(ctx, EmbeddingCacheKey, 0, -CacheSize-1).Err()
-	if err != nil {
-		return fmt.Errorf("failed to trim embedding cache: %w", err)
-	}
-
-	return nil
-}
-
-func GetCachedEmbeddings(ctx context.Context) ([][]float32, error) {
-
-	embeddings, err := redisClient.ZRange(ctx, EmbeddingCacheKey, 0, -1).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get cached embeddings: %w", err)
-	}
-
-	var result [][]float32
-	for _, encodedEmbedding := range embeddings {
-		embeddingBytes, err := base64.StdEncoding.DecodeString(encodedEmbedding)
-		if err != nil {
-			continue
-		}
-
-		// Convert bytes back to []float32
-		embedding := make([]float32, len(embeddingBytes)/4)
-		for i := 0; i < len(embeddingBytes); i += 4 {
-			bits := uint32(embeddingBytes[i]) | uint32(embeddingBytes[i+1])<<8 |
-				uint32(embeddingBytes[i+2])<<16 | uint32(embeddingBytes[i+3])<<24
-			embedding[i/4] = math.Float32frombits(bits)
-		}
-		result = append(result, embedding)
-	}
-
-	return result, nil
-}
-
-func FindSimilarEmbedding(query []float32, threshold float32) ([]float32, bool) {
-	ctx := context.Background()
-	cachedEmbeddings, err := GetCachedEmbeddings(ctx)
-	if err != nil {
-		return nil, false
-	}
-
-	for _, cached := range cachedEmbeddings {
-		similarity := cosineSimilarity(query, cached)
-		if similarity > threshold {
-			return cached, true
-		}
-	}
-	return nil, false
-}
-
 func AngularSimilarity(a, b []float32) float32 {
 	if len(a) != len(b) {
 		return 0
@@ -128,76 +43,56 @@ func AngularSimilarity(a, b []float32) float32 {
 	return 1 - angle/math.Pi
 }
 
-func cosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
-		return 0
-	}
-
-	var dotProduct float32
-	var normA float32
-	var normB float32
-
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
-	}
-
-	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
+// Scraper resolves TED results against the loaded transcript dataset and extracts
+// every other result with the Web scraper.
+type Scraper struct {
+	TED *TEDCorpus
+	Web *ReadabilityScraper
 }
 
-func Scrape(result embedstore.Result, tedTalks []TEDTalk) (string, error) {
+func (s *Scraper) Scrape(ctx context.Context, result embedstore.Result) (*Article, error) {
 	if result.Link == "" {
-		return "", nil
+		return &Article{Title: result.Title}, nil
 	}
 
 	if result.IsTED {
-		fmt.Println("audio scraping")
-		s := scrapeTedUrl(result, tedTalks)
-		fmt.Println("FETCHED FROM ted DB : ", s)
-		return s, nil
-	} else {
-		return fetchURLContent(result.Link, 3, 1*time.Second, 5*time.Second)
+		talk := s.scrapeTedUrl(ctx, result)
+		if talk == nil {
+			return &Article{Title: result.Title}, nil
+		}
+		return talk.Article(), nil
 	}
+	return s.Web.Scrape(ctx, result)
 }
 
-func scrapeTedUrl(result embedstore.Result, tedTalks []TEDTalk) string {
-	req, err := http.NewRequest("GET", result.Link, nil)
-	if err != nil {
-		log.Println("Error creating request:", err)
-		return ""
+// scrapeTedUrl fetches the talk page with the Web scraper's client and timeout and
+// looks its title up in the TED corpus.
+func (s *Scraper) scrapeTedUrl(ctx context.Context, result embedstore.Result) *TEDTalk {
+	if s.TED.Len() == 0 {
+		return nil
 	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Println("Error fetching the URL:", err)
-		return ""
+	web := s.Web
+	if web == nil {
+		web = NewReadabilityScraper(nil)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println("Non-OK HTTP status:", resp)
-		return ""
+	body, err := web.get(ctx, result.Link)
+	if err != nil {
+		log.Printf("Error fetching TED page %s: %v", result.Link, err)
+		return nil
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		log.Println("Error loading HTML document:", err)
-		return ""
+		return nil
 	}
 
-	fmt.Println("Ted talk scraping")
-	s := scrapeTEDTalk(doc, tedTalks)
-	fmt.Println("FETCHED FROM ted DB : ", s)
-	return s
-
+	talk := scrapeTEDTalk(doc, s.TED)
+	if talk != nil {
+		log.Printf("Fetched TED talk %q from the corpus", talk.Title)
+	}
+	return talk
 }
 
 func ExtractContent(doc *goquery.Document) string {
@@ -233,72 +128,29 @@ func scrapeWebPage(doc *goquery.Document) string {
 	return text
 }
 
-func fetchURLContent(url string, maxRetries int, retryDelay time.Duration, timeout time.Duration) (string, error) {
-	log.Printf("Scraping content from URL: %s", url)
-	retries := 0
+// cleanText collapses runs of spaces and tabs inside each line and squeezes blank
+// lines down to a single "\n\n", so paragraph breaks survive for the chunker.
+func cleanText(text string) string {
 
-	client := &http.Client{
-		Timeout: timeout,
-	}
+	// text = stripHTMLTags(text)
 
-	for retries < maxRetries {
-		resp, err := client.Get(url)
-		if err != nil {
-			log.Printf("Error occurred while scraping URL: %s. Error: %v", url, err)
-			retries++
-			time.Sleep(retryDelay)
+	var paragraphs []string
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
 			continue
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			doc, err := goquery.NewDocumentFromReader(resp.Body)
-			if err != nil {
-				log.Printf("Error parsing document from URL: %s. Error: %v", url, err)
-				return "", err
-			}
-
-			selectors := []string{
-				"article",
-				"div.main-content",
-				"body",
-			}
-
-			var mainText string
-			for _, selector := range selectors {
-				doc.Find(selector).Each(func(i int, s *goquery.Selection) {
-					text := cleanText(s.Text())
-					if text != "" {
-						mainText += text + "\n"
-					}
-				})
-				if mainText != "" {
-					break
-				}
-			}
-
-			if strings.TrimSpace(mainText) == "" {
-				log.Printf("No content extracted from URL: %s", url)
-			}
-			return mainText, nil
-		} else {
-			log.Printf("Request failed with status code: %d", resp.StatusCode)
-			return "", fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+			lines = nil
 		}
 	}
-
-	log.Printf("Failed to scrape content from URL: %s after %d retries.", url, maxRetries)
-	return "", fmt.Errorf("failed to scrape content from URL: %s after %d retries", url, maxRetries)
-}
-
-func cleanText(text string) string {
-
-	// text = stripHTMLTags(text)
-
-	text = strings.TrimSpace(text)
-	text = strings.ReplaceAll(text, "\n", " ")
-	text = strings.Join(strings.Fields(text), " ")
-	return text
+	if len(lines) > 0 {
+		paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+	}
+	return strings.Join(paragraphs, "\n\n")
 }
 
 func stripHTMLTags(text string) string {
@@ -310,6 +162,31 @@ type TEDTalk struct {
 	Title   string `json:"title"`
 	Output  string `json:"output"`
 	Speaker string `json:"speaker"`
+	// Subtitles optionally holds the talk as SRT or WebVTT so chunks can be cited by timestamp.
+	Subtitles string `json:"subtitles,omitempty"`
+}
+
+// Article converts the talk into an article titled "Speaker - Title", carrying its
+// subtitle cues when the dataset has them.
+func (t TEDTalk) Article() *Article {
+	title := t.Title
+	if t.Speaker != "" {
+		title = t.Speaker + " - " + t.Title
+	}
+	article := &Article{Title: title, Language: "en", Text: t.Output}
+
+	if t.Subtitles != "" {
+		cues, err := subtitle.Parse(t.Subtitles)
+		if err != nil {
+			log.Printf("ignoring unparseable subtitles for %s: %v", title, err)
+		} else {
+			article.Cues = cues
+			if article.Text == "" {
+				article.Text = subtitle.Text(cues)
+			}
+		}
+	}
+	return article
 }
 
 func isTEDTalk(url string) bool {
@@ -342,55 +219,21 @@ func Similarity(a, b string) float64 {
 	return (1 - float64(distance)/float64(maxLen)) * 100
 }
 
-func SearchTEDTalk(title, speaker string, tedTalks []TEDTalk) string {
-	for _, talk := range tedTalks {
-		s := Similarity(talk.Title, title)
-		if s >= 70 {
-			fmt.Print("Similarity bw : ", talk.Title, title, "is ", s)
-			return fmt.Sprintf("%s", talk.Output)
-		}
+func scrapeTEDTalk(doc *goquery.Document, corpus *TEDCorpus) *TEDTalk {
+	title, speaker := ParseTEDPageTitle(doc.Find("meta[property='og:title']").AttrOr("content", ""))
+	if name := strings.TrimSpace(doc.Find(".talk-speaker__name").Text()); name != "" {
+		speaker = name
 	}
-	return "No matching TED Talk found."
-}
 
-func scrapeTEDTalk(doc *goquery.Document, tedTalks []TEDTalk) string {
-	title := doc.Find("meta[property='og:title']").AttrOr("content", "No title found")
-	speaker := doc.Find(".talk-speaker__name").Text()
-
-	// fmt.Print("TED DETAILS : ", title, "XXXX ", speaker)
-
-	if title != "" || speaker != "" {
-		return SearchTEDTalk(title, speaker, tedTalks)
-	} else {
-		return "Could not extract TED Talk details."
+	if title == "" && speaker == "" {
+		log.Println("Could not extract TED Talk details.")
+		return nil
 	}
-}
-
-// TODO
-func scrapeAndParse(url string) (schema.Document, error) {
-	// resp, err := http.Get(url)
-	// if err != nil {
-	// 	return schema.Document{}, err
-	// }
-	// defer resp.Body.Close()
-
-	// body, err := ioutil.ReadAll(resp.Body)
-	// if err != nil {
-	// 	return schema.Document{}, err
-	// }
-
-	article, err := readability.FromURL(url, 10*time.Second)
-	if err != nil {
-		log.Println("Error loading HTML document:", err)
-		return schema.Document{}, nil
+	match, ok := corpus.Best(title, speaker)
+	if !ok {
+		log.Printf("No matching TED Talk found for %q by %q", title, speaker)
+		return nil
 	}
-
-	// Create a Document object
-	return schema.Document{
-		PageContent: article.Content,
-		Metadata: map[string]any{
-			"name": article.Title,
-			"url":  url,
-		},
-	}, nil
+	log.Printf("Matched TED Talk %q with score %.2f", match.Talk.Title, match.Score)
+	return match.Talk
 }
@@ -0,0 +1,84 @@
+package extract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"spaces inside lines", "  Hello \t  world  ", "Hello world"},
+		{"paragraph break kept", "First  paragraph.\n\nSecond paragraph.", "First paragraph.\n\nSecond paragraph."},
+		{"blank lines squeezed", "One.\n \t\n\n\r\nTwo.\n\n\n", "One.\n\nTwo."},
+		{"line break kept", "Line one\nline two\r\n", "Line one\nline two"},
+		{"whitespace only", " \n\n\t ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanText(tt.in); got != tt.want {
+				t.Errorf("cleanText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeTEDPage(t *testing.T) {
+	corpus := NewTEDCorpus([]TEDTalk{{Title: "Do schools kill creativity?", Speaker: "Ken Robinson", Output: "Good morning."}})
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "matched",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<html><head><meta property="og:title" content="Ken Robinson: Do schools kill creativity? | TED Talk"></head></html>`))
+			},
+			want: "Good morning.",
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+		},
+		{
+			name: "slow page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			web := NewReadabilityScraper(srv.Client())
+			web.Timeout = 50 * time.Millisecond
+			s := &Scraper{TED: corpus, Web: web}
+
+			start := time.Now()
+			article, err := s.Scrape(context.Background(), embedstore.Result{Title: "Search title", Link: srv.URL + "/talks/x", IsTED: true})
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if article.Text != tt.want {
+				t.Errorf("article text = %q, want %q", article.Text, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Scrape took %v despite the web timeout", elapsed)
+			}
+		})
+	}
+}
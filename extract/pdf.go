@@ -0,0 +1,65 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Page is the plain text of one PDF page. Number is 1-based.
+type Page struct {
+	Number int
+	Text   string
+}
+
+// PDFDocument is the text extracted from a PDF, page by page.
+type PDFDocument struct {
+	Title string
+	Pages []Page
+}
+
+// ExtractPDF extracts the plain text of every page of a PDF. Pages without
+// extractable text (e.g. scanned images) are skipped.
+func ExtractPDF(data []byte) (doc *PDFDocument, err error) {
+	// The parser panics on some malformed files instead of returning an error.
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error opening PDF: %w", err)
+	}
+
+	doc = &PDFDocument{
+		Title: strings.TrimSpace(r.Trailer().Key("Info").Key("Title").Text()),
+	}
+
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+		text, err := p.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("error reading page %d: %w", i, err)
+		}
+		text = cleanText(text)
+		if text == "" {
+			continue
+		}
+		doc.Pages = append(doc.Pages, Page{Number: i, Text: text})
+	}
+	return doc, nil
+}
@@ -0,0 +1,187 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	readability "github.com/go-shiori/go-readability"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+const (
+	userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+
+	// maxPageBytes bounds how much of a page is read before parsing.
+	maxPageBytes = 10 << 20
+	// minReadableChars is the shortest readability output trusted over the selector fallback.
+	minReadableChars = 200
+)
+
+// Article is the cleaned content of a scraped page.
+type Article struct {
+	Title     string
+	Byline    string
+	Published time.Time
+	Language  string
+	Text      string
+	// Cues is set for timed transcripts; chunks are then cut along cue boundaries.
+	Cues []subtitle.Cue
+}
+
+// ReadabilityScraper fetches web pages and extracts the main article with
+// go-readability, falling back to ExtractContent's selector list when readability
+// finds nothing useful.
+type ReadabilityScraper struct {
+	Client     *http.Client
+	Timeout    time.Duration
+	MaxRetries int
+	RetryDelay time.Duration
+}
+
+func NewReadabilityScraper(client *http.Client) *ReadabilityScraper {
+	return &ReadabilityScraper{
+		Client:     client,
+		Timeout:    5 * time.Second,
+		MaxRetries: 3,
+		RetryDelay: 1 * time.Second,
+	}
+}
+
+func (s *ReadabilityScraper) Scrape(ctx context.Context, result embedstore.Result) (*Article, error) {
+	article, err := s.Fetch(ctx, result.Link)
+	if err != nil {
+		return nil, err
+	}
+	if article.Title == "" {
+		article.Title = result.Title
+	}
+	return article, nil
+}
+
+// Fetch downloads link, retrying transport errors, and parses it.
+func (s *ReadabilityScraper) Fetch(ctx context.Context, link string) (*Article, error) {
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", link, err)
+	}
+
+	maxRetries := s.MaxRetries
+	if maxRetries < 1 {
+		maxRetries = 1
+	}
+
+	var body []byte
+	for attempt := 1; ; attempt++ {
+		body, err = s.get(ctx, link)
+		if err == nil {
+			break
+		}
+		var status statusError
+		if attempt >= maxRetries || ctx.Err() != nil || errors.As(err, &status) {
+			return nil, err
+		}
+		log.Printf("Error occurred while scraping URL: %s. Error: %v", link, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.RetryDelay):
+		}
+	}
+
+	return ParseArticle(body, pageURL)
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("request failed with status code: %d", int(e))
+}
+
+func (s *ReadabilityScraper) get(ctx context.Context, link string) ([]byte, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+}
+
+// ParseArticle extracts the article from raw HTML. pageURL resolves relative links
+// and may be nil.
+func ParseArticle(body []byte, pageURL *url.URL) (*Article, error) {
+	if pageURL == nil {
+		pageURL = &url.URL{}
+	}
+
+	parsed, err := readability.FromReader(bytes.NewReader(body), pageURL)
+	if err != nil {
+		log.Printf("readability failed for %s, using selector fallback: %v", pageURL, err)
+	}
+
+	article := &Article{
+		Title:    strings.TrimSpace(parsed.Title),
+		Byline:   strings.TrimSpace(parsed.Byline),
+		Language: parsed.Language,
+		Text:     cleanText(parsed.TextContent),
+	}
+	if parsed.PublishedTime != nil {
+		article.Published = *parsed.PublishedTime
+	}
+
+	if len(article.Text) >= minReadableChars && article.Language != "" {
+		return article, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		if article.Text != "" {
+			return article, nil
+		}
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+	if article.Language == "" {
+		article.Language = strings.TrimSpace(doc.Find("html").AttrOr("lang", ""))
+	}
+	if article.Title == "" {
+		article.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	if len(article.Text) < minReadableChars {
+		doc.Find("script, style, noscript").Remove()
+		if text := cleanText(ExtractContent(doc)); len(text) > len(article.Text) {
+			article.Text = text
+		}
+	}
+	return article, nil
+}
@@ -0,0 +1,30 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchRetryHonoursContext(t *testing.T) {
+	// A closed server refuses connections, which Fetch treats as retryable.
+	srv := httptest.NewServer(nil)
+	link := srv.URL + "/article"
+	srv.Close()
+
+	s := NewReadabilityScraper(nil)
+	s.RetryDelay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.Fetch(ctx, link)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Fetch error = %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Fetch waited %v despite the cancelled context", elapsed)
+	}
+}
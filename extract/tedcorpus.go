@@ -0,0 +1,305 @@
+package extract
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TEDMatch is a candidate talk for a lookup. Score is in [0, 1].
+type TEDMatch struct {
+	Talk  *TEDTalk
+	Score float64
+}
+
+// TEDCorpus is the TED transcript dataset, loaded once and indexed by normalized title
+// and speaker. Titles are also indexed by trigram so near-miss titles (punctuation,
+// typos, "Speaker: Title" page titles) still resolve.
+type TEDCorpus struct {
+	// MinScore is the lowest score Best accepts.
+	MinScore float64
+
+	talks     []TEDTalk
+	byTitle   map[string][]int
+	bySpeaker map[string][]int
+	trigrams  map[string][]int
+	titleGram []map[string]struct{}
+}
+
+// LoadTEDCorpus reads and indexes the dataset at path. See ReadTEDTalks for the
+// accepted formats.
+func LoadTEDCorpus(path string) (*TEDCorpus, error) {
+	talks, err := ReadTEDTalks(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewTEDCorpus(talks), nil
+}
+
+func NewTEDCorpus(talks []TEDTalk) *TEDCorpus {
+	c := &TEDCorpus{
+		MinScore:  0.6,
+		talks:     talks,
+		byTitle:   make(map[string][]int),
+		bySpeaker: make(map[string][]int),
+		trigrams:  make(map[string][]int),
+		titleGram: make([]map[string]struct{}, len(talks)),
+	}
+	for i, talk := range talks {
+		title := normalizeName(talk.Title)
+		c.byTitle[title] = append(c.byTitle[title], i)
+		if speaker := normalizeName(talk.Speaker); speaker != "" {
+			c.bySpeaker[speaker] = append(c.bySpeaker[speaker], i)
+		}
+		c.titleGram[i] = trigrams(title)
+		for g := range c.titleGram[i] {
+			c.trigrams[g] = append(c.trigrams[g], i)
+		}
+	}
+	return c
+}
+
+// Len returns the number of talks in the corpus.
+func (c *TEDCorpus) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.talks)
+}
+
+// Talks returns every talk in the corpus.
+func (c *TEDCorpus) Talks() []TEDTalk {
+	if c == nil {
+		return nil
+	}
+	return c.talks
+}
+
+// Match returns up to limit talks ranked by how well they match title and speaker.
+// Either may be empty. The title score is the trigram Dice coefficient; when both
+// sides have a speaker it is blended with the speaker score.
+func (c *TEDCorpus) Match(title, speaker string, limit int) []TEDMatch {
+	if c.Len() == 0 {
+		return nil
+	}
+	title, speaker = normalizeName(title), normalizeName(speaker)
+
+	candidates := make(map[int]struct{})
+	query := trigrams(title)
+	for g := range query {
+		for _, i := range c.trigrams[g] {
+			candidates[i] = struct{}{}
+		}
+	}
+	for _, i := range c.byTitle[title] {
+		candidates[i] = struct{}{}
+	}
+	if title == "" {
+		for _, i := range c.bySpeaker[speaker] {
+			candidates[i] = struct{}{}
+		}
+	}
+
+	matches := make([]TEDMatch, 0, len(candidates))
+	for i := range candidates {
+		talk := &c.talks[i]
+		score := dice(query, c.titleGram[i])
+		if title == "" {
+			score = 0
+		}
+		if talkSpeaker := normalizeName(talk.Speaker); speaker != "" && talkSpeaker != "" {
+			s := dice(trigrams(speaker), trigrams(talkSpeaker))
+			if title == "" {
+				score = s
+			} else {
+				score = 0.8*score + 0.2*s
+			}
+		}
+		if score > 0 {
+			matches = append(matches, TEDMatch{Talk: talk, Score: score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Talk.Title < matches[j].Talk.Title
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Best returns the top match if it scores at least MinScore.
+func (c *TEDCorpus) Best(title, speaker string) (TEDMatch, bool) {
+	matches := c.Match(title, speaker, 1)
+	if len(matches) == 0 || matches[0].Score < c.MinScore {
+		return TEDMatch{}, false
+	}
+	return matches[0], true
+}
+
+// ReadTEDTalks reads a TED dataset. The format is picked by extension:
+//   - .json: an array of {"title", "speaker", "output", "subtitles"} objects
+//   - .jsonl: one such object per line
+//   - .csv: a header row naming the columns; "transcript" and "main_speaker" are
+//     accepted for output and speaker so Kaggle exports load as-is
+func ReadTEDTalks(path string) ([]TEDTalk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		var talks []TEDTalk
+		if err := json.NewDecoder(f).Decode(&talks); err != nil {
+			return nil, fmt.Errorf("error unmarshalling %s: %w", path, err)
+		}
+		return talks, nil
+	case ".jsonl":
+		return readTEDJSONL(f, path)
+	case ".csv":
+		return readTEDCSV(f, path)
+	default:
+		return nil, fmt.Errorf("unsupported TED dataset format %q", ext)
+	}
+}
+
+func readTEDJSONL(r io.Reader, path string) ([]TEDTalk, error) {
+	var talks []TEDTalk
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var talk TEDTalk
+		if err := json.Unmarshal([]byte(text), &talk); err != nil {
+			return nil, fmt.Errorf("error unmarshalling %s line %d: %w", path, line, err)
+		}
+		talks = append(talks, talk)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return talks, nil
+}
+
+// tedCSVColumns maps accepted CSV header names to TEDTalk fields. A column named
+// after the field itself wins over its aliases wherever it appears; between aliases
+// the first one wins.
+var tedCSVColumns = map[string]string{
+	"title":        "title",
+	"name":         "title",
+	"speaker":      "speaker",
+	"main_speaker": "speaker",
+	"output":       "output",
+	"transcript":   "output",
+	"text":         "output",
+	"subtitles":    "subtitles",
+}
+
+func readTEDCSV(r io.Reader, path string) ([]TEDTalk, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s header: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := tedCSVColumns[name]; ok {
+			if _, seen := columns[field]; !seen || name == field {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("%s has no title column", path)
+	}
+
+	get := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var talks []TEDTalk
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		talks = append(talks, TEDTalk{
+			Title:     get(record, "title"),
+			Speaker:   get(record, "speaker"),
+			Output:    get(record, "output"),
+			Subtitles: get(record, "subtitles"),
+		})
+	}
+	return talks, nil
+}
+
+// ParseTEDPageTitle splits a ted.com page title such as
+// "Ken Robinson: Do schools kill creativity? | TED Talk" into title and speaker.
+func ParseTEDPageTitle(pageTitle string) (title, speaker string) {
+	title = strings.TrimSpace(pageTitle)
+	if i := strings.LastIndex(title, " | "); i >= 0 {
+		title = strings.TrimSpace(title[:i])
+	}
+	if before, after, ok := strings.Cut(title, ": "); ok {
+		return strings.TrimSpace(after), strings.TrimSpace(before)
+	}
+	return title, ""
+}
+
+func normalizeName(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func trigrams(s string) map[string]struct{} {
+	grams := make(map[string]struct{})
+	if s == "" {
+		return grams
+	}
+	runes := []rune(" " + s + " ")
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+func dice(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
@@ -0,0 +1,223 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testTEDCorpus() *TEDCorpus {
+	return NewTEDCorpus([]TEDTalk{
+		{Title: "Do schools kill creativity?", Speaker: "Ken Robinson"},
+		{Title: "The power of vulnerability", Speaker: "Brené Brown"},
+		{Title: "How great leaders inspire action", Speaker: "Simon Sinek"},
+		{Title: "Your body language may shape who you are", Speaker: "Amy Cuddy"},
+		{Title: "Bring on the learning revolution!", Speaker: "Ken Robinson"},
+	})
+}
+
+func TestTEDCorpusBest(t *testing.T) {
+	c := testTEDCorpus()
+	if c.MinScore != 0.6 {
+		t.Fatalf("MinScore = %v, want 0.6", c.MinScore)
+	}
+	tests := []struct {
+		name, title, speaker string
+		want                 string
+	}{
+		{"exact title", "Do schools kill creativity?", "", "Do schools kill creativity?"},
+		{"case and punctuation", "DO SCHOOLS KILL CREATIVITY", "", "Do schools kill creativity?"},
+		{"typo", "Do schols kill creativty?", "", "Do schools kill creativity?"},
+		{"title and speaker", "The power of vulnerability", "Brene Brown", "The power of vulnerability"},
+		{"wrong speaker", "How great leaders inspire action", "Amy Cuddy", "How great leaders inspire action"},
+		{"speaker only", "", "Simon Sinek", "How great leaders inspire action"},
+		{"unrelated title", "A brief history of jazz", "", ""},
+		{"partial title", "creativity", "", ""},
+		{"nothing", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := c.Best(tt.title, tt.speaker)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("Best(%q, %q) = %q (%.2f), want no match", tt.title, tt.speaker, match.Talk.Title, match.Score)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Best(%q, %q) found nothing, want %q", tt.title, tt.speaker, tt.want)
+			}
+			if match.Talk.Title != tt.want || match.Score < c.MinScore || match.Score > 1 {
+				t.Errorf("Best(%q, %q) = %q (%.2f), want %q", tt.title, tt.speaker, match.Talk.Title, match.Score, tt.want)
+			}
+		})
+	}
+}
+
+func TestTEDCorpusMatch(t *testing.T) {
+	c := testTEDCorpus()
+
+	// Both Ken Robinson talks score the same on speaker alone, so they come back in
+	// title order.
+	var titles []string
+	for _, m := range c.Match("", "Ken Robinson", 0) {
+		titles = append(titles, m.Talk.Title)
+	}
+	if want := []string{"Bring on the learning revolution!", "Do schools kill creativity?"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("speaker matches = %q, want %q", titles, want)
+	}
+
+	matches := c.Match("the power of", "", 0)
+	if len(matches) < 2 || matches[0].Talk.Title != "The power of vulnerability" {
+		t.Fatalf("matches = %+v, want the vulnerability talk first", matches)
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Score > matches[i-1].Score {
+			t.Errorf("match %d scores %.2f above match %d at %.2f", i, matches[i].Score, i-1, matches[i-1].Score)
+		}
+	}
+	if got := c.Match("the power of", "", 1); len(got) != 1 || got[0].Talk != matches[0].Talk {
+		t.Errorf("limit 1 = %+v, want only the best match", got)
+	}
+
+	var empty *TEDCorpus
+	if got := empty.Match("anything", "", 5); got != nil {
+		t.Errorf("nil corpus matched %+v", got)
+	}
+}
+
+func TestReadTEDTalks(t *testing.T) {
+	want := []TEDTalk{
+		{Title: "Do schools kill creativity?", Speaker: "Ken Robinson", Output: "Good morning. How are you?"},
+		{Title: "The power of vulnerability", Speaker: "Brené Brown", Output: "So, I'll start with this."},
+	}
+	tests := []struct {
+		name, file, data string
+		want             []TEDTalk
+		wantErr          string
+	}{
+		{
+			name: "json",
+			file: "talks.json",
+			data: `[{"title": "Do schools kill creativity?", "speaker": "Ken Robinson", "output": "Good morning. How are you?"},
+				{"title": "The power of vulnerability", "speaker": "Brené Brown", "output": "So, I'll start with this."}]`,
+			want: want,
+		},
+		{
+			name: "jsonl with blank lines",
+			file: "talks.jsonl",
+			data: `{"title": "Do schools kill creativity?", "speaker": "Ken Robinson", "output": "Good morning. How are you?"}
+
+{"title": "The power of vulnerability", "speaker": "Brené Brown", "output": "So, I'll start with this."}
+`,
+			want: want,
+		},
+		{
+			name:    "jsonl bad line",
+			file:    "talks.jsonl",
+			data:    "{\"title\": \"ok\"}\nnot json\n",
+			wantErr: "line 2",
+		},
+		{
+			name: "csv",
+			file: "talks.csv",
+			data: "title,speaker,output\n" +
+				"Do schools kill creativity?,Ken Robinson,Good morning. How are you?\n" +
+				"The power of vulnerability,Brené Brown,\"So, I'll start with this.\"\n",
+			want: want,
+		},
+		{
+			name: "csv kaggle columns with bom",
+			file: "talks.CSV",
+			data: "\ufeffname, Main_Speaker ,views,transcript\n" +
+				"Do schools kill creativity?,Ken Robinson,100,Good morning. How are you?\n" +
+				"The power of vulnerability,Brené Brown,200,\"So, I'll start with this.\"\n",
+			want: want,
+		},
+		{
+			name: "csv short record",
+			file: "talks.csv",
+			data: "title,speaker,output\nDo schools kill creativity?\n",
+			want: []TEDTalk{{Title: "Do schools kill creativity?"}},
+		},
+		{
+			name:    "csv without title",
+			file:    "talks.csv",
+			data:    "speaker,output\nKen Robinson,Good morning.\n",
+			wantErr: "has no title column",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "talks.xml",
+			data:    "<talks/>",
+			wantErr: "unsupported TED dataset format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			talks, err := ReadTEDTalks(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadTEDTalks error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadTEDTalks: %v", err)
+			}
+			if !reflect.DeepEqual(talks, tt.want) {
+				t.Errorf("ReadTEDTalks = %+v, want %+v", talks, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTEDCSVTitleAndName(t *testing.T) {
+	// "title" and "name" both map to the title; the column named title wins
+	// whichever comes first.
+	for _, header := range []string{"title,name", "name,title"} {
+		t.Run(header, func(t *testing.T) {
+			row := "Real title,Event name"
+			if header == "name,title" {
+				row = "Event name,Real title"
+			}
+			path := filepath.Join(t.TempDir(), "talks.csv")
+			if err := os.WriteFile(path, []byte(header+"\n"+row+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			talks, err := ReadTEDTalks(path)
+			if err != nil {
+				t.Fatalf("ReadTEDTalks: %v", err)
+			}
+			if len(talks) != 1 || talks[0].Title != "Real title" {
+				t.Errorf("ReadTEDTalks = %+v, want title %q", talks, "Real title")
+			}
+		})
+	}
+}
+
+func TestParseTEDPageTitle(t *testing.T) {
+	tests := []struct {
+		in, title, speaker string
+	}{
+		{"Ken Robinson: Do schools kill creativity? | TED Talk", "Do schools kill creativity?", "Ken Robinson"},
+		{"Brené Brown: The power of vulnerability | TED", "The power of vulnerability", "Brené Brown"},
+		{"Simon Sinek: How great leaders inspire action", "How great leaders inspire action", "Simon Sinek"},
+		{"A talk without a speaker | TED Talk", "A talk without a speaker", ""},
+		{"Speaker: Title: with a colon | TED Talk", "Title: with a colon", "Speaker"},
+		{"  Padded title  ", "Padded title", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		title, speaker := ParseTEDPageTitle(tt.in)
+		if title != tt.title || speaker != tt.speaker {
+			t.Errorf("ParseTEDPageTitle(%q) = %q, %q; want %q, %q", tt.in, title, speaker, tt.title, tt.speaker)
+		}
+	}
+}
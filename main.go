@@ -2,17 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/gorilla/websocket"
@@ -20,13 +22,16 @@ import (
 	"google.golang.org/api/option"
 
 	"Audio-LLM-Contextual-Heygen/audio"
+	"Audio-LLM-Contextual-Heygen/chunker"
 	"Audio-LLM-Contextual-Heygen/embedstore"
 	"Audio-LLM-Contextual-Heygen/extract"
+	"Audio-LLM-Contextual-Heygen/pipeline"
+	"Audio-LLM-Contextual-Heygen/search"
+	"Audio-LLM-Contextual-Heygen/transcribe"
 )
 
 const (
-	googleSearchURL = "https://www.googleapis.com/customsearch/v1"
-	geminiAPIURL    = "https://api.gemini.com/v1/embedding"
+	geminiAPIURL = "https://api.gemini.com/v1/embedding"
 )
 
 type Result struct {
@@ -48,6 +53,40 @@ var (
 	neo4jURI  string
 	neo4jUser string
 	neo4jPass string
+
+	adminToken   string
+	qdrantConfig embedstore.QdrantConfig
+	qdrantStore  *embedstore.QdrantStore
+	// vectorBackend is "qdrant" (default), "memory" for exact in-process search or
+	// "hnsw" for the in-process HNSW index. In-process collections are snapshotted to
+	// snapshotDir when it is set.
+	vectorBackend string
+	snapshotDir   string
+	searxngURL    string
+	whisperBin    string
+	whisperModel  string
+	localCorpus   string
+	corpus        *search.Local
+	tedDataset    string
+	tedCorpus     *extract.TEDCorpus
+
+	tokenCounter chunker.Counter = chunker.Approx
+	// embedderName picks the embedding backend: "gemini" (default) or "hash" for the
+	// offline feature-hashing embedder.
+	embedderName string
+	embeddingDim = 768
+	// embeddingCache persists Gemini vectors across requests and restarts; nil when
+	// EMBEDDING_CACHE is "off" or the file could not be opened.
+	embeddingCachePath string
+	embeddingCache     *embedstore.EmbeddingCache
+	// semanticSources lists the source types (web, ted, pdf) chunked by topic shift
+	// instead of fixed token windows.
+	semanticSources []string
+	// hybridMode is the default fusion of dense and keyword hits; "off" also skips
+	// building keyword indexes.
+	hybridMode = pipeline.FusionRRF
+	// rerankerName picks the reranking stage: "llm", "lexical" or "" for none.
+	rerankerName string
 )
 
 func loadEnvVars() {
@@ -66,25 +105,98 @@ func loadEnvVars() {
 		fmt.Println("Warning: G_API_KEY is not set")
 	}
 
+	// Text-to-Speech accepts the same Google Cloud key unless a separate one is given.
+	audio.GoogleCloudAPIKey = os.Getenv("GOOGLE_CLOUD_API_KEY")
+	if audio.GoogleCloudAPIKey == "" {
+		audio.GoogleCloudAPIKey = apiKey
+	}
+
 	neo4jURI = os.Getenv("NEO4J_URI")
 	neo4jUser = os.Getenv("NEO4J_USER")
 	neo4jPass = os.Getenv("NEO4J_PASS")
+
+	adminToken = os.Getenv("ADMIN_TOKEN")
+	qdrantConfig = embedstore.QdrantConfig{
+		Addr:   os.Getenv("QDRANT_ADDR"),
+		TLS:    os.Getenv("QDRANT_TLS") == "true",
+		APIKey: os.Getenv("QDRANT_API_KEY"),
+	}
+	vectorBackend = os.Getenv("VECTOR_STORE")
+	if vectorBackend == "" {
+		vectorBackend = "qdrant"
+	}
+	snapshotDir = os.Getenv("VECTOR_SNAPSHOT_DIR")
+	searxngURL = os.Getenv("SEARXNG_URL")
+	whisperBin = os.Getenv("WHISPER_BIN")
+	whisperModel = os.Getenv("WHISPER_MODEL")
+	localCorpus = os.Getenv("LOCAL_CORPUS")
+
+	embedderName = os.Getenv("EMBEDDER")
+	if dim := os.Getenv("EMBEDDING_DIM"); dim != "" {
+		n, err := strconv.Atoi(dim)
+		if err != nil || n < 1 {
+			fmt.Println("Warning: ignoring invalid EMBEDDING_DIM", dim)
+		} else {
+			embeddingDim = n
+		}
+	}
+
+	embeddingCachePath = os.Getenv("EMBEDDING_CACHE")
+	if embeddingCachePath == "" {
+		embeddingCachePath = "embeddings.cache"
+	}
+
+	semanticSources = splitList(os.Getenv("SEMANTIC_CHUNKING"))
+
+	switch mode := pipeline.FusionMode(os.Getenv("HYBRID_SEARCH")); mode {
+	case "":
+	case pipeline.FusionOff, pipeline.FusionRRF, pipeline.FusionWeighted:
+		hybridMode = mode
+	default:
+		fmt.Println("Warning: ignoring invalid HYBRID_SEARCH", mode)
+	}
+
+	rerankerName = os.Getenv("RERANKER")
+	switch rerankerName {
+	case "", "llm", "lexical":
+	default:
+		fmt.Println("Warning: ignoring invalid RERANKER", rerankerName)
+		rerankerName = ""
+	}
+
+	tedDataset = os.Getenv("TED_DATASET")
+	if tedDataset == "" {
+		tedDataset = "new_op.json"
+	}
 }
 
-func handleVideoUpload(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("video")
+// maxMediaUploadBytes caps the size of recordings accepted by /upload/media.
+const maxMediaUploadBytes = 512 << 20
+
+// handleMediaUpload transcribes an uploaded video or audio recording and stores the
+// timestamped transcript so avatars can reference it.
+func handleMediaUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUploadBytes)
+	file, header, err := r.FormFile("media")
 	if err != nil {
 		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	tempFile, err := os.CreateTemp("", "video-*.mp4")
+	name := filepath.Base(header.Filename)
+	tempFile, err := os.CreateTemp("", "media-*"+filepath.Ext(name))
 	if err != nil {
 		http.Error(w, "Error creating temporary file", http.StatusInternalServerError)
 		return
 	}
 	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
 
 	_, err = io.Copy(tempFile, file)
 	if err != nil {
@@ -92,22 +204,126 @@ func handleVideoUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	extractedText, err := extractTextFromVideo(tempFile.Name())
+	ctx := r.Context()
+	segments, err := newTranscriber().Transcribe(ctx, tempFile.Name())
+	if err != nil {
+		log.Println("Error transcribing media:", err)
+		http.Error(w, "Error transcribing media", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(segments) == 0 {
+		http.Error(w, "No speech found in media", http.StatusUnprocessableEntity)
+		return
+	}
+
+	client, err := genai.NewClient(ctx, option.WithAPIKey(g_Api_Key))
 	if err != nil {
-		http.Error(w, "Error extracting text from video", http.StatusInternalServerError)
+		log.Println("Error creating Gemini client:", err)
+		http.Error(w, "Error storing the transcript", http.StatusInternalServerError)
 		return
 	}
+	defer client.Close()
 
-	json.NewEncoder(w).Encode(map[string]string{"extractedText": extractedText})
+	session := r.FormValue("session")
+	kb, err := openCollection(ctx, session)
+	if err != nil {
+		log.Println("Error opening collection:", err)
+		http.Error(w, "Error storing the transcript", http.StatusInternalServerError)
+		return
+	}
+
+	chunks, err := newPipeline(client, kb).IngestTranscript(ctx, name, segments)
+	if err != nil {
+		log.Println("Error ingesting transcript:", err)
+		http.Error(w, "Error storing the transcript", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file":     name,
+		"segments": len(segments),
+		"chunks":   chunks,
+		"duration": embedstore.FormatTimestamp(segments[len(segments)-1].End),
+	})
 }
 
-func extractTextFromVideo(videoPath string) (string, error) {
-	cmd := exec.Command("ffmpeg", "-i", videoPath, "-vf", "subtitles=subtitles.srt", "-f", "rawvideo", "-")
-	output, err := cmd.CombinedOutput()
+// newTranscriber uses embedded subtitles when present and falls back to a local
+// whisper.cpp install when WHISPER_BIN and WHISPER_MODEL are set.
+func newTranscriber() *transcribe.Transcriber {
+	t := &transcribe.Transcriber{}
+	if whisperBin != "" && whisperModel != "" {
+		t.STT = &transcribe.WhisperCPP{Binary: whisperBin, Model: whisperModel}
+	}
+	return t
+}
+
+// maxPDFUploadBytes caps the size of PDFs accepted by /upload/pdf.
+const maxPDFUploadBytes = 32 << 20
+
+func handlePDFUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPDFUploadBytes)
+	file, header, err := r.FormFile("pdf")
+	if err != nil {
+		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Error reading the file", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := extract.ExtractPDF(data)
+	if err != nil {
+		log.Println("Error extracting text from PDF:", err)
+		http.Error(w, "Error extracting text from PDF", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(doc.Pages) == 0 {
+		http.Error(w, "PDF contains no extractable text", http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx := r.Context()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(g_Api_Key))
 	if err != nil {
-		return "", fmt.Errorf("ffmpeg command failed: %v", err)
+		log.Println("Error creating Gemini client:", err)
+		http.Error(w, "Error storing the PDF", http.StatusInternalServerError)
+		return
 	}
-	return string(output), nil
+	defer client.Close()
+
+	session := r.FormValue("session")
+	kb, err := openCollection(ctx, session)
+	if err != nil {
+		log.Println("Error opening collection:", err)
+		http.Error(w, "Error storing the PDF", http.StatusInternalServerError)
+		return
+	}
+
+	name := filepath.Base(header.Filename)
+	chunks, err := newPipeline(client, kb).IngestPDF(ctx, name, doc)
+	if err != nil {
+		log.Println("Error ingesting PDF:", err)
+		http.Error(w, "Error storing the PDF", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file":   name,
+		"title":  doc.Title,
+		"pages":  len(doc.Pages),
+		"chunks": chunks,
+	})
 }
 
 func updateGraphDB(query, answer string) error {
@@ -138,6 +354,9 @@ func updateGraphDB(query, answer string) error {
 	return err
 }
 
+// httpClient is shared by every outbound search backend so connections are pooled.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -145,6 +364,11 @@ var upgrader = websocket.Upgrader{
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	opts, err := requestOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Error upgrading to WebSocket:", err)
@@ -152,16 +376,33 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(g_Api_Key))
+	if err != nil {
+		log.Println("Error creating Gemini client:", err)
+		return
+	}
+	defer client.Close()
+
+	session := r.URL.Query().Get("session")
+	kb, err := openCollection(ctx, session)
+	if err != nil {
+		log.Println("Error opening collection:", err)
+		return
+	}
+	p := newPipeline(client, kb)
+	opts.apply(p)
+
 	for {
-		messageType, p, err := conn.ReadMessage()
+		messageType, msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Println("Error reading message:", err)
 			return
 		}
 
 		if messageType == websocket.TextMessage {
-			query := string(p)
-			response, err := handleUserInteraction(query)
+			query := string(msg)
+			response, err := p.Run(ctx, query)
 			if err != nil {
 				log.Println("Error handling user interaction:", err)
 				continue
@@ -182,141 +423,394 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func handleUserInteraction(query string) (string, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(g_Api_Key))
-	if err != nil {
-		return "", err
+// newPipeline wires the Google/TED search, scraping, Gemini and Qdrant stages used by /search and the WebSocket handler.
+func newPipeline(client *genai.Client, kb *knowledgeBase) *pipeline.Pipeline {
+	p := pipeline.New()
+	p.Session = kb.Session
+	if kb.Keywords != nil {
+		p.Keywords = kb.Keywords
+	}
+	p.Hybrid.Mode = hybridMode
+	p.Chunker = chunker.New(tokenCounter, 1000, 100)
+	p.Searcher = search.NewFusion(
+		search.Source{Name: "google", Searcher: search.NewGoogle(httpClient, apiKey, cxID, 8)},
+		search.Source{Name: "ted", Searcher: search.NewTED(httpClient, apiKey, cxID, 3)},
+	)
+	p.Scraper = &extract.Scraper{TED: tedCorpus, Web: extract.NewReadabilityScraper(httpClient)}
+	gemini := embedstore.NewGeminiEmbedder(client, "embedding-001")
+	gemini.Cache = embeddingCache
+	var embedder interface {
+		pipeline.Embedder
+		chunker.Embedder
+	} = gemini
+	if embedderName == "hash" {
+		embedder = embedstore.NewHashEmbedder(embeddingDim)
+		// Hashed vectors only overlap on shared words, so their cosine scores run
+		// much lower than Gemini's.
+		p.ScoreThreshold = 0.1
+	}
+	p.Embedder = embedder
+	p.Store = kb.Store
+	p.Generator = geminiGenerator{model: client.GenerativeModel("gemini-1.5-flash")}
+	p.Sink = neo4jSink{}
+	switch rerankerName {
+	case "llm":
+		p.Reranker = pipeline.NewLLMReranker(geminiGenerator{model: client.GenerativeModel("gemini-1.5-flash"), quiet: true})
+	case "lexical":
+		p.Reranker = pipeline.LexicalReranker{}
+	}
+
+	if len(semanticSources) > 0 {
+		semantic := chunker.NewSemantic(embedder, tokenCounter)
+		p.Chunkers = make(map[string]chunker.Splitter)
+		for _, source := range semanticSources {
+			p.Chunkers[source] = semantic
+		}
 	}
-	defer client.Close()
 
-	model := client.GenerativeModel("gemini-1.5-flash")
-	response, err := queryLLM(ctx, model, client, query, 300)
-	if err != nil {
-		return "", err
+	// Self-hosted SearxNG replaces Google CSE when no API keys are available.
+	if searxngURL != "" {
+		ted := search.NewSearxNG(httpClient, searxngURL, 3)
+		ted.QueryPrefix = "TED Talk"
+		ted.IsTED = true
+		p.Searcher = search.NewFusion(
+			search.Source{Name: "searxng", Searcher: search.NewSearxNG(httpClient, searxngURL, 8)},
+			search.Source{Name: "searxng-ted", Searcher: ted},
+		)
 	}
 
-	return formatResponse(response), nil
+	// Offline mode: search and scrape the local corpus instead of the web.
+	if corpus != nil {
+		p.Searcher = corpus
+		p.Scraper = corpus
+	}
+	return p
 }
 
-var totalChunks = 0
+// retrievalOptions are the per-request retrieval settings of /search and the
+// WebSocket handler.
+type retrievalOptions struct {
+	Filter    embedstore.Filter
+	Hybrid    pipeline.Hybrid
+	Diversity pipeline.Diversity
+}
 
-func GoogleSearch(query string, maxResults int, resultsCh chan<- embedstore.Result, linkSet map[string]struct{}) {
-	u, err := url.Parse(googleSearchURL)
-	if err != nil {
-		log.Fatal(err)
+func requestOptions(values url.Values) (retrievalOptions, error) {
+	var opts retrievalOptions
+	var err error
+	if opts.Filter, err = requestFilter(values); err != nil {
+		return opts, err
+	}
+	if opts.Hybrid, err = requestHybrid(values); err != nil {
+		return opts, err
 	}
-	q := u.Query()
-	q.Set("q", url.QueryEscape(query))
-	q.Set("key", apiKey)
-	q.Set("cx", cxID)
-	u.RawQuery = q.Encode()
+	opts.Diversity, err = requestDiversity(values)
+	return opts, err
+}
 
-	// Log the full URL for debugging purposes
-	log.Println("Google Search URL:", u.String())
+func (o retrievalOptions) apply(p *pipeline.Pipeline) {
+	p.Filter = o.Filter
+	p.Hybrid = o.Hybrid
+	p.Diversity = o.Diversity
+}
 
-	resp, err := http.Get(u.String())
-	if err != nil {
-		log.Println("Error making request to Google Search API:", err)
-		return
+// requestFilter reads the retrieval filter from query parameters: comma-separated
+// source, domain and language lists, and since/until as RFC 3339 times or, for
+// since, a duration back from now such as "24h".
+func requestFilter(values url.Values) (embedstore.Filter, error) {
+	filter := embedstore.Filter{
+		Sources:   splitList(values.Get("source")),
+		Domains:   splitList(values.Get("domain")),
+		Languages: splitList(values.Get("language")),
+	}
+	if since := values.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			filter.IngestedAfter = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.IngestedAfter = t
+		} else {
+			return filter, fmt.Errorf("invalid since %q: want a duration like 24h or an RFC 3339 time", since)
+		}
+	}
+	if until := values.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until %q: want an RFC 3339 time", until)
+		}
+		filter.IngestedBefore = t
 	}
-	defer resp.Body.Close()
+	return filter, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println("Non-OK HTTP status:", resp.StatusCode, resp)
-		return
+// requestHybrid reads per-request fusion settings: hybrid=off|rrf|weighted and alpha,
+// the dense share of weighted scores in (0,1]. Use hybrid=off for dense-only results;
+// there is no keyword-only setting.
+func requestHybrid(values url.Values) (pipeline.Hybrid, error) {
+	h := pipeline.Hybrid{Mode: hybridMode}
+	switch mode := pipeline.FusionMode(values.Get("hybrid")); mode {
+	case "":
+	case pipeline.FusionOff, pipeline.FusionRRF, pipeline.FusionWeighted:
+		h.Mode = mode
+	default:
+		return h, fmt.Errorf("invalid hybrid %q: want off, rrf or weighted", mode)
+	}
+	if alpha := values.Get("alpha"); alpha != "" {
+		a, err := strconv.ParseFloat(alpha, 64)
+		if err != nil || a <= 0 || a > 1 {
+			return h, fmt.Errorf("invalid alpha %q: want a number above 0 and at most 1", alpha)
+		}
+		h.Alpha = a
 	}
+	return h, nil
+}
 
-	var response struct {
-		Items []embedstore.Result `json:"items"`
+// requestDiversity reads the MMR settings: lambda, the weight of relevance against
+// novelty between 0 and 1 (lower is more diverse, 0 turns MMR off), and per_link, the
+// most chunks taken from one page.
+func requestDiversity(values url.Values) (pipeline.Diversity, error) {
+	d := pipeline.DefaultDiversity
+	if lambda := values.Get("lambda"); lambda != "" {
+		l, err := strconv.ParseFloat(lambda, 64)
+		if err != nil || l < 0 || l > 1 {
+			return d, fmt.Errorf("invalid lambda %q: want a number between 0 and 1", lambda)
+		}
+		d.Lambda = l
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		log.Println("Error decoding response:", err)
-		return
+	if perLink := values.Get("per_link"); perLink != "" {
+		n, err := strconv.Atoi(perLink)
+		if err != nil || n < 0 {
+			return d, fmt.Errorf("invalid per_link %q: want a non-negative integer", perLink)
+		}
+		d.MaxPerLink = n
 	}
+	return d, nil
+}
 
-	for i, result := range response.Items {
-		if i >= maxResults {
-			break
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
 		}
-		// if _, exists := linkSet[result.Link]; !exists {
-		// linkSet[result.Link] = struct{}{}
-		resultsCh <- result
-		// }
 	}
+	return list
 }
 
-func TedSearch(query string, maxResults int, resultsCh chan<- embedstore.Result, linkSet map[string]struct{}) {
-	u, err := url.Parse(googleSearchURL)
-	if err != nil {
-		log.Fatal(err)
+// ensuredCollections remembers which collections EnsureCollection already checked so
+// requests only pay for the lookup once per process.
+var ensuredCollections sync.Map
+
+// memoryStores holds the in-process collections by name, with the version last
+// snapshotted for each.
+var (
+	memoryMu      sync.Mutex
+	memoryStores  = make(map[string]*embedstore.MemoryStore)
+	savedVersions = make(map[string]uint64)
+)
+
+// knowledgeBase is what a session retrieves from: its collection's vector store and,
+// unless hybrid search is off, the keyword index kept beside it.
+type knowledgeBase struct {
+	Session  string
+	Store    pipeline.VectorStore
+	Keywords *embedstore.KeywordIndex
+}
+
+// scrollStore is a vector store whose chunks can be listed to fill a keyword index.
+type scrollStore interface {
+	pipeline.VectorStore
+	Scroll(ctx context.Context, fn func(embedstore.ChunkData)) error
+}
+
+// openCollection returns the knowledge base for a session, creating its collection
+// on first use. Existing collections are never wiped.
+func openCollection(ctx context.Context, session string) (*knowledgeBase, error) {
+	collection := embedstore.CollectionForSession(session)
+	var store scrollStore
+	if vectorBackend != "qdrant" {
+		store = memoryCollection(collection)
+	} else {
+		qdrant := qdrantStore.WithCollection(collection)
+		if _, ok := ensuredCollections.Load(collection); !ok {
+			if err := qdrant.EnsureCollection(ctx, embeddingDim); err != nil {
+				return nil, err
+			}
+			ensuredCollections.Store(collection, true)
+		}
+		store = qdrant
 	}
-	q := u.Query()
-	q.Set("q", fmt.Sprintf("TED Talk %s", url.QueryEscape(query)))
-	q.Set("key", apiKey)
-	q.Set("cx", cxID)
-	u.RawQuery = q.Encode()
 
-	// Log the full URL for debugging purposes
-	fmt.Println("TED Search URL:", u.String())
+	kb := &knowledgeBase{Session: session, Store: store}
+	if hybridMode != pipeline.FusionOff {
+		kb.Keywords = keywordIndex(ctx, collection, store)
+	}
+	return kb, nil
+}
 
-	resp, err := http.Get(u.String())
-	if err != nil {
-		fmt.Println("Error making request to Google Search API - TED:", err)
-		return
+// keywordIndexes holds the BM25 index of every opened collection.
+var (
+	keywordMu      sync.Mutex
+	keywordIndexes = make(map[string]*embedstore.KeywordIndex)
+)
+
+// keywordIndex returns the keyword index for collection, filling it from store the
+// first time so chunks stored by earlier runs are searchable too.
+func keywordIndex(ctx context.Context, collection string, store scrollStore) *embedstore.KeywordIndex {
+	keywordMu.Lock()
+	defer keywordMu.Unlock()
+	if index, ok := keywordIndexes[collection]; ok {
+		return index
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Non-OK HTTP status:", resp.StatusCode)
-		return
+	var chunks []embedstore.ChunkData
+	if err := store.Scroll(ctx, func(chunk embedstore.ChunkData) { chunks = append(chunks, chunk) }); err != nil {
+		log.Printf("Warning: keyword index for %s starts empty: %v", collection, err)
+	}
+	index := embedstore.NewKeywordIndex()
+	index.Add(chunks)
+	keywordIndexes[collection] = index
+	if len(chunks) > 0 {
+		log.Printf("Indexed %d chunks of %s for keyword search", len(chunks), collection)
 	}
+	return index
+}
 
-	var response struct {
-		Items []embedstore.Result `json:"items"`
+// memoryCollection returns the in-process store for collection, restoring its
+// snapshot the first time it is opened.
+func memoryCollection(collection string) *embedstore.MemoryStore {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	if store, ok := memoryStores[collection]; ok {
+		return store
+	}
+
+	var store *embedstore.MemoryStore
+	if snapshotDir != "" {
+		path := snapshotPath(collection)
+		loaded, err := embedstore.LoadMemoryStore(path)
+		switch {
+		case err == nil:
+			log.Printf("Loaded %d chunks into %s from %s", loaded.Len(), collection, path)
+			store = loaded
+			savedVersions[collection] = store.Version()
+		case !os.IsNotExist(err):
+			log.Printf("Warning: starting %s empty, snapshot not loaded: %v", collection, err)
+		}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		fmt.Println("Error decoding response:", err)
-		return
+	if store == nil && vectorBackend == "hnsw" {
+		store = embedstore.NewHNSWStore(embedstore.HNSWConfig{})
+	} else if store == nil {
+		store = embedstore.NewMemoryStore()
 	}
+	memoryStores[collection] = store
+	return store
+}
 
-	for i, result := range response.Items {
-		if i >= maxResults {
-			break
+func snapshotPath(collection string) string {
+	return filepath.Join(snapshotDir, collection+".snapshot")
+}
+
+// saveSnapshots writes every in-process collection that changed since its last
+// snapshot.
+func saveSnapshots() {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	for collection, store := range memoryStores {
+		version := store.Version()
+		if saved, ok := savedVersions[collection]; ok && saved == version {
+			continue
+		}
+		if err := store.Save(snapshotPath(collection)); err != nil {
+			log.Printf("Error saving snapshot of %s: %v", collection, err)
+			continue
 		}
-		// if _, exists := linkSet[result.Link]; !exists {
-		// linkSet[result.Link] = struct{}{}
-		fmt.Println("Ted result : ", result)
-		result.IsTED = true
-		resultsCh <- result
-		// }
+		savedVersions[collection] = version
 	}
 }
 
-var talkMap = make(map[string]extract.TEDTalk)
+// handleCollectionReset wipes a session's collection (or the default one). It is only
+// enabled when ADMIN_TOKEN is set and requires it as a bearer token.
+func handleCollectionReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if adminToken == "" {
+		http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-func LoadTEDTalks(filename string) ([]extract.TEDTalk, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+	collection := embedstore.CollectionForSession(r.FormValue("session"))
+	if vectorBackend != "qdrant" {
+		memoryCollection(collection).Reset()
+		if snapshotDir != "" {
+			saveSnapshots()
+		}
+	} else {
+		store := qdrantStore.WithCollection(collection)
+		if err := store.ResetCollection(r.Context(), embeddingDim); err != nil {
+			log.Println("Error resetting collection:", err)
+			http.Error(w, "Error resetting the collection", http.StatusInternalServerError)
+			return
+		}
+		ensuredCollections.Store(store.Collection, true)
 	}
-	defer file.Close()
+	keywordMu.Lock()
+	delete(keywordIndexes, collection)
+	keywordMu.Unlock()
+	log.Printf("Collection %s reset", collection)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"collection": collection,
+		"dimension":  embeddingDim,
+	})
+}
+
+type geminiGenerator struct {
+	model *genai.GenerativeModel
+	// quiet returns just the response text without echoing it, for internal calls
+	// such as reranker grading.
+	quiet bool
+}
 
-	byteValue, err := ioutil.ReadAll(file)
+func (g geminiGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := queryLLM(ctx, g.model, nil, prompt, 300)
 	if err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+		return "", err
 	}
-
-	var tedTalks []extract.TEDTalk
-	if err := json.Unmarshal(byteValue, &tedTalks); err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON: %v", err)
+	if g.quiet {
+		return responseText(resp), nil
 	}
+	return printResponseTest(resp), nil
+}
 
-	for _, talk := range tedTalks {
-		key := fmt.Sprintf("%s|%s", talk.Title, talk.Speaker)
-		talkMap[key] = talk
+// responseText joins the text parts of every candidate.
+func responseText(resp *genai.GenerateContentResponse) string {
+	var sb strings.Builder
+	for _, cand := range resp.Candidates {
+		if cand.Content == nil {
+			continue
+		}
+		for _, part := range cand.Content.Parts {
+			if text, ok := part.(genai.Text); ok {
+				sb.WriteString(string(text))
+				sb.WriteString("\n")
+			}
+		}
 	}
-	return tedTalks, nil
+	return sb.String()
+}
+
+type neo4jSink struct{}
+
+func (neo4jSink) Record(ctx context.Context, query, answer string) error {
+	return updateGraphDB(query, answer)
 }
 
 type LLMRequest struct {
@@ -329,15 +823,6 @@ type LLMResponse struct {
 	Answer string `json:"answer"`
 }
 
-func queryLLMTest(ctx context.Context, model *genai.GenerativeModel, client *genai.Client, llmQuery string, maxTokens int) (s string) {
-	resp, err := model.GenerateContent(ctx, genai.Text(llmQuery))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return printResponseTest(resp)
-}
-
 func printResponseTest(resp *genai.GenerateContentResponse) (s string) {
 	x := ""
 	for _, cand := range resp.Candidates {
@@ -361,25 +846,64 @@ func queryLLM(ctx context.Context, model *genai.GenerativeModel, client *genai.C
 	return resp, nil
 }
 
-func formatResponse(resp *genai.GenerateContentResponse) string {
-	var sb strings.Builder
-	for _, cand := range resp.Candidates {
-		if cand.Content != nil {
-			for _, part := range cand.Content.Parts {
-				x := fmt.Sprintln(part)
-				fmt.Println("CLIENT OP : ", x)
-				sb.WriteString(x)
-				sb.WriteString("\n")
+func main() {
+
+	loadEnvVars()
+
+	var err error
+	switch vectorBackend {
+	case "qdrant":
+		qdrantStore, err = embedstore.NewQdrantStore(qdrantConfig)
+		if err != nil {
+			log.Fatalf("Error configuring Qdrant: %v", err)
+		}
+		defer qdrantStore.Close()
+	case "memory", "hnsw":
+		log.Printf("Using the in-process %s vector store", vectorBackend)
+		if snapshotDir != "" {
+			if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+				log.Fatalf("Error creating snapshot directory: %v", err)
 			}
+			go func() {
+				for range time.Tick(30 * time.Second) {
+					saveSnapshots()
+				}
+			}()
 		}
+	default:
+		log.Fatalf("Unknown VECTOR_STORE %q, want qdrant, memory or hnsw", vectorBackend)
 	}
-	sb.WriteString("---\n")
-	return sb.String()
-}
 
-func main() {
+	tedCorpus, err = extract.LoadTEDCorpus(tedDataset)
+	if err != nil {
+		log.Printf("Warning: TED dataset not loaded, TED results will be skipped: %v", err)
+	} else {
+		log.Printf("Loaded %d TED talks from %s", tedCorpus.Len(), tedDataset)
+	}
 
-	loadEnvVars()
+	if embeddingCachePath != "off" {
+		embeddingCache, err = embedstore.OpenEmbeddingCache(embeddingCachePath)
+		if err != nil {
+			log.Printf("Warning: embedding cache disabled: %v", err)
+		} else {
+			defer embeddingCache.Close()
+			log.Printf("Loaded %d cached embeddings from %s", embeddingCache.Len(), embeddingCachePath)
+		}
+	}
+
+	if tk, err := chunker.NewTiktoken("cl100k_base"); err != nil {
+		log.Printf("Warning: tiktoken unavailable, estimating chunk token counts: %v", err)
+	} else {
+		tokenCounter = tk
+	}
+
+	if localCorpus != "" {
+		corpus, err = search.NewLocal(localCorpus, 8)
+		if err != nil {
+			log.Fatalf("Error loading local corpus: %v", err)
+		}
+		log.Printf("Loaded %d documents from local corpus %s", corpus.Len(), localCorpus)
+	}
 
 	// query := flag.String("query", "", "Search query")
 	// flag.Parse()
@@ -395,6 +919,12 @@ func main() {
 			return
 		}
 
+		opts, err := requestOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Handling spaces in the query parameter
 		fmt.Println("Before ", query)
 		query = strings.ReplaceAll(query, "+", " ")
@@ -407,115 +937,35 @@ func main() {
 		}
 		defer client.Close()
 
-		fmt.Println("loading json")
-		tedTalks, _ := LoadTEDTalks("new_op.json")
-		dimension := 768
-
-		fmt.Printf("Embedding dimensions: %d\n", dimension)
-
-		// Setup of Qdrant collection with the dimension
-		embedstore.SetupQdrantCollection(dimension)
-
-		// Channel to receive search results and a wait group till evry gets bback
-		resultsCh := make(chan embedstore.Result)
-		var wg sync.WaitGroup
-
-		linkSet := make(map[string]struct{})
-
-		wg.Add(2)
-		go func() {
-			defer wg.Done()
-			GoogleSearch(query, 8, resultsCh, linkSet)
-		}()
-		go func() {
-			defer wg.Done()
-			TedSearch(query, 3, resultsCh, linkSet)
-		}()
-
-		// end recv
-		go func() {
-			wg.Wait()
-			close(resultsCh)
-		}()
-
-		// Process each result from the search results channel
-		var processWg sync.WaitGroup
-		for result := range resultsCh {
-			fmt.Println("Title:", result.Title)
-			fmt.Println("Link:", result.Link)
-			processWg.Add(1)
-			go func(result embedstore.Result) {
-				// Scrape the content from the search result link
-				defer processWg.Done()
-				// content, _ := scrape(result, tedTalks)
-				content, _ := extract.Scrape(result, tedTalks)
-				// fmt.Println("CONTENTTTT : ", document.PageContent)
-				// content := document.PageContent
-				if content != "" {
-					// Generating an embedding for the scraped content
-					embedstore.GetGeminiEmbedding(ctx, client, content, "embedding-001", result.Title, result, false)
-					// print("Embeddingsssqherr LEN ", len(embedding))
-					if err != nil {
-						log.Println("Error getting embedding:", err)
-						return
-					}
-				}
-				return
-			}(result)
-
-		}
-		processWg.Wait()
-		fmt.Printf("Total embeddings : %d\n", totalChunks)
-		fmt.Println()
-
-		// Generate an embedding for the search query
-
-		queryEmbedding := embedstore.GetGeminiEmbedding(ctx, client, query, "embedding-001", "abc", embedstore.Result{}, true)
+		session := r.URL.Query().Get("session")
+		kb, err := openCollection(ctx, session)
 		if err != nil {
-			log.Fatalf("Error generating query embedding: %v", err)
-		}
-		print("QEMED : ", queryEmbedding)
-
-		// Search for similar embeddings in Qdrant using the query embedding
-		limit := 10
-		var scoreThreshold float32 = 0.6
-		chunkIDs, err := embedstore.SearchQdrant(queryEmbedding, limit, scoreThreshold)
-		if err != nil {
-			log.Fatalf("Error searching Qdrant: %v", err)
+			log.Println("Error opening collection:", err)
+			http.Error(w, "Error opening the knowledge base", http.StatusInternalServerError)
+			return
 		}
 
-		// Retrieve the content chunks corresponding to the found chunk IDs
-		chunks, err := embedstore.GetChunks(chunkIDs)
+		p := newPipeline(client, kb)
+		opts.apply(p)
+		s, err := p.Run(ctx, query)
 		if err != nil {
-			log.Fatalf("Error retrieving chunks: %v", err)
+			log.Println("Error running search pipeline:", err)
+			http.Error(w, "Error answering query", http.StatusInternalServerError)
+			return
 		}
+		w.Write([]byte(s))
 
-		// chunks := chunkToDocuments(c, 8192)
-
-		fmt.Println("CHONSKKS ", "$$$", chunks)
-
-		// fmt.Print("CHUNKKSKSKS", chunks)
-		// Combininng the retrieved chunks into a single context string
-		instruction := `You are a helpful AI assistant that helps users answer queries using the provided context. If you cant frame an answer from the context given, copy paste directly from context rather than making up an answer. Please provide a detailed answer to the query below only using the context provided. Include in-text citations like this [1] for each fact or statement at the end of the sentence. At the end of your response, list all sources in a citation section with the format: [citation number] Name - URL.`
-
-		context := ""
-		for _, chunk := range chunks {
-			if chunk.Text == query {
-				continue
-			}
-			context += "Title of the website where the following paragraph was obtained from -> " + chunk.Title + ". Link of the website -> " + chunk.Link + " . Paragraph -> " + chunk.Text + " . End of that paragraph.\n Starting new paragraph :  \n"
+		if embeddingCache != nil {
+			hits, misses := embeddingCache.Stats()
+			log.Printf("Embedding cache: %d vectors, %d hits, %d misses", embeddingCache.Len(), hits, misses)
 		}
-		llmquery := "INSTRUCTION : " + instruction + ". QUERY : " + query + ". CONTEXT : " + context + "."
-		model := client.GenerativeModel("gemini-1.5-flash")
-		fmt.Print("LLM QUERY FINAL : ", llmquery)
-
-		s := queryLLMTest(ctx, model, client, llmquery, 300)
-		// s := invokeLLMChain(ctx, model, client, chunks, query)
-		updateGraphDB(query, s)
-		w.Write([]byte(s))
 
 	})
 
+	http.HandleFunc("/upload/pdf", handlePDFUpload)
+	http.HandleFunc("/upload/media", handleMediaUpload)
+	http.HandleFunc("/admin/collections/reset", handleCollectionReset)
+
 	log.Println("Starting server on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
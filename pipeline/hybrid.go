@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"sort"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// KeywordIndex is the sparse side of hybrid retrieval, kept in step with the vector
+// store by Ingest. embedstore.KeywordIndex implements it.
+type KeywordIndex interface {
+	Add(chunks []embedstore.ChunkData)
+	Search(query string, limit int, filter embedstore.Filter) []embedstore.ChunkData
+}
+
+// FusionMode says how Retrieve merges the dense and keyword rankings.
+type FusionMode string
+
+const (
+	// FusionOff uses the dense ranking only.
+	FusionOff FusionMode = "off"
+	// FusionRRF sums reciprocal ranks, ignoring the raw scores.
+	FusionRRF FusionMode = "rrf"
+	// FusionWeighted min-max normalizes both score lists and mixes them with Alpha.
+	FusionWeighted FusionMode = "weighted"
+)
+
+// defaultRRFK is the rank constant from the original reciprocal-rank fusion paper.
+const defaultRRFK = 60
+
+// Hybrid configures hybrid retrieval. The zero value fuses with RRF whenever the
+// pipeline has a KeywordIndex.
+type Hybrid struct {
+	Mode FusionMode
+	// Alpha is the dense share of the weighted score, in (0,1]. Zero, or anything
+	// outside that range, means 0.5.
+	Alpha float64
+	// K is the RRF rank constant; zero means 60.
+	K float64
+}
+
+// fuse merges the dense and keyword rankings into one list of up to limit chunks,
+// best first, with Score set to the fused score. Chunks are matched by point ID.
+func fuse(dense, sparse []embedstore.ChunkData, h Hybrid, limit int) []embedstore.ChunkData {
+	type entry struct {
+		chunk embedstore.ChunkData
+		score float64
+	}
+	merged := make(map[string]*entry)
+	var order []string
+	add := func(chunk embedstore.ChunkData, score float64) {
+		id := embedstore.PointID(chunk)
+		e, ok := merged[id]
+		if !ok {
+			e = &entry{chunk: chunk}
+			merged[id] = e
+			order = append(order, id)
+		}
+		e.score += score
+	}
+
+	if h.Mode == FusionWeighted {
+		alpha := h.Alpha
+		if alpha <= 0 || alpha > 1 {
+			alpha = 0.5
+		}
+		for i, score := range normalizeScores(dense) {
+			add(dense[i], alpha*score)
+		}
+		for i, score := range normalizeScores(sparse) {
+			add(sparse[i], (1-alpha)*score)
+		}
+	} else {
+		k := h.K
+		if k <= 0 {
+			k = defaultRRFK
+		}
+		for rank, chunk := range dense {
+			add(chunk, 1/(k+float64(rank+1)))
+		}
+		for rank, chunk := range sparse {
+			add(chunk, 1/(k+float64(rank+1)))
+		}
+	}
+
+	// Stable sort over first-seen order so ties keep the dense ranking.
+	sort.SliceStable(order, func(i, j int) bool { return merged[order[i]].score > merged[order[j]].score })
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	chunks := make([]embedstore.ChunkData, len(order))
+	for i, id := range order {
+		chunks[i] = merged[id].chunk
+		chunks[i].Score = float32(merged[id].score)
+	}
+	return chunks
+}
+
+// normalizeScores maps the chunks' scores onto 0..1. A list whose scores are all
+// equal maps to 1.
+func normalizeScores(chunks []embedstore.ChunkData) []float64 {
+	if len(chunks) == 0 {
+		return nil
+	}
+	lo, hi := chunks[0].Score, chunks[0].Score
+	for _, c := range chunks {
+		lo, hi = min(lo, c.Score), max(hi, c.Score)
+	}
+	scores := make([]float64, len(chunks))
+	for i, c := range chunks {
+		if hi == lo {
+			scores[i] = 1
+		} else {
+			scores[i] = float64(c.Score-lo) / float64(hi-lo)
+		}
+	}
+	return scores
+}
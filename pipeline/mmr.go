@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// Diversity configures the maximal marginal relevance step that picks the final
+// chunks from the retrieved candidates, so the prompt covers more distinct sources
+// instead of ten neighbouring chunks of one page.
+type Diversity struct {
+	// Lambda trades relevance (1) against novelty (towards 0). Zero skips MMR and
+	// keeps the retrieval order.
+	Lambda float64
+	// MaxPerLink caps the chunks taken from one link; zero means no cap.
+	MaxPerLink int
+}
+
+// DefaultDiversity is the Diversity New starts with.
+var DefaultDiversity = Diversity{Lambda: 0.7, MaxPerLink: 3}
+
+// diversify picks up to limit chunks. Each step takes the chunk maximizing
+// Lambda*relevance - (1-Lambda)*similarity to the chunks already picked, where
+// relevance is the retrieval score scaled to 0..1 and similarity is the cosine of the
+// chunk vectors. Chunks that came back without a vector, e.g. keyword-only hits, are
+// embedded first in one extra call. Lambda zero needs no vectors and only applies
+// the per-link cap.
+func (p *Pipeline) diversify(ctx context.Context, chunks []embedstore.ChunkData, limit int) ([]embedstore.ChunkData, error) {
+	d := p.Diversity
+	if d.Lambda <= 0 {
+		return capPerLink(chunks, d.MaxPerLink, limit), nil
+	}
+	if err := p.fillVectors(ctx, chunks); err != nil {
+		return nil, err
+	}
+
+	relevance := normalizeScores(chunks)
+	// maxSim[i] is candidate i's highest similarity to any picked chunk.
+	maxSim := make([]float64, len(chunks))
+	used := make([]bool, len(chunks))
+	perLink := make(map[string]int)
+	var picked []embedstore.ChunkData
+	for len(picked) < limit {
+		best, bestScore := -1, math.Inf(-1)
+		for i, chunk := range chunks {
+			if used[i] || d.MaxPerLink > 0 && perLink[chunk.Link] >= d.MaxPerLink {
+				continue
+			}
+			score := d.Lambda*relevance[i] - (1-d.Lambda)*maxSim[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		perLink[chunks[best].Link]++
+		picked = append(picked, chunks[best])
+		for i := range chunks {
+			if !used[i] {
+				maxSim[i] = math.Max(maxSim[i], cosine(chunks[i].Vector, chunks[best].Vector))
+			}
+		}
+	}
+	return picked, nil
+}
+
+// fillVectors embeds the chunks that have no vector, e.g. keyword-only hits.
+func (p *Pipeline) fillVectors(ctx context.Context, chunks []embedstore.ChunkData) error {
+	var missing []int
+	var texts []embedstore.ChunkData
+	for i, chunk := range chunks {
+		if len(chunk.Vector) == 0 {
+			missing = append(missing, i)
+			texts = append(texts, chunk)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	vectors, err := p.Embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks for diversification: %w", err)
+	}
+	if len(vectors) != len(missing) {
+		return fmt.Errorf("embedder returned %d vectors for %d chunks", len(vectors), len(missing))
+	}
+	for j, i := range missing {
+		chunks[i].Vector = vectors[j]
+	}
+	return nil
+}
+
+// capPerLink keeps chunks in order, skipping any beyond maxPerLink per link.
+func capPerLink(chunks []embedstore.ChunkData, maxPerLink, limit int) []embedstore.ChunkData {
+	perLink := make(map[string]int)
+	var kept []embedstore.ChunkData
+	for _, chunk := range chunks {
+		if len(kept) == limit {
+			break
+		}
+		if maxPerLink > 0 && perLink[chunk.Link] >= maxPerLink {
+			continue
+		}
+		perLink[chunk.Link]++
+		kept = append(kept, chunk)
+	}
+	return kept
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
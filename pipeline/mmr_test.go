@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// mmrCandidates are retrieval hits: two near-duplicates from one page ahead of a
+// less relevant but different chunk from another page.
+func mmrCandidates() []embedstore.ChunkData {
+	return []embedstore.ChunkData{
+		{Link: "a", Text: "a1", Score: 0.9, Vector: []float32{1, 0}},
+		{Link: "a", Text: "a2", Score: 0.89, Vector: []float32{0.99, 0.1}},
+		{Link: "b", Text: "b1", Score: 0.8, Vector: []float32{0, 1}},
+		{Link: "a", Text: "a3", Score: 0.7, Vector: []float32{0.9, 0.3}},
+	}
+}
+
+func texts(chunks []embedstore.ChunkData) string {
+	var out []string
+	for _, c := range chunks {
+		out = append(out, c.Text)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestDiversify(t *testing.T) {
+	tests := []struct {
+		name      string
+		diversity Diversity
+		limit     int
+		// drop removes the candidates' vectors, as for keyword-only hits.
+		drop      bool
+		want      string
+		wantEmbed int
+	}{
+		{name: "relevance only", diversity: Diversity{Lambda: 1}, limit: 3, want: "a1,a2,b1"},
+		{name: "novelty beats a near-duplicate", diversity: Diversity{Lambda: 0.5}, limit: 3, want: "a1,b1,a2"},
+		{name: "per-link cap", diversity: Diversity{Lambda: 1, MaxPerLink: 2}, limit: 4, want: "a1,a2,b1"},
+		{name: "limit", diversity: Diversity{Lambda: 0.5}, limit: 1, want: "a1"},
+		{name: "lambda zero keeps the order", diversity: Diversity{}, limit: 3, want: "a1,a2,b1"},
+		{name: "lambda zero still caps", diversity: Diversity{MaxPerLink: 1}, limit: 3, drop: true, want: "a1,b1"},
+		{name: "missing vectors are embedded", diversity: Diversity{Lambda: 0.5}, limit: 2, drop: true, want: "a1,b1", wantEmbed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder := &fakeEmbedder{}
+			p := &Pipeline{Embedder: embedder, Diversity: tt.diversity}
+			chunks := mmrCandidates()
+			if tt.drop {
+				// The fake embedder maps these texts onto its vocabulary.
+				for i := range chunks {
+					chunks[i].Vector = nil
+					chunks[i].Text = map[string]string{"a1": "cats", "a2": "cats", "b1": "rockets", "a3": "cats music"}[chunks[i].Text]
+				}
+			}
+
+			got, err := p.diversify(context.Background(), chunks, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if tt.drop {
+				want = strings.NewReplacer("a1", "cats", "b1", "rockets").Replace(want)
+			}
+			if texts(got) != want {
+				t.Errorf("got %s, want %s", texts(got), want)
+			}
+			if embedder.docCalls != tt.wantEmbed {
+				t.Errorf("embedder called %d times, want %d", embedder.docCalls, tt.wantEmbed)
+			}
+		})
+	}
+}
+
+func TestCapPerLink(t *testing.T) {
+	chunks := mmrCandidates()
+	if got := texts(capPerLink(chunks, 1, 10)); got != "a1,b1" {
+		t.Errorf("cap 1: got %s", got)
+	}
+	if got := texts(capPerLink(chunks, 0, 3)); got != "a1,a2,b1" {
+		t.Errorf("no cap: got %s", got)
+	}
+}
+
+// vectorStore is a fakeStore that only returns vectors from SearchWithVectors, like
+// QdrantStore.
+type vectorStore struct {
+	fakeStore
+	withVectors, without int
+}
+
+func (s *vectorStore) Search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter embedstore.Filter) ([]embedstore.ChunkData, error) {
+	s.without++
+	hits, err := s.fakeStore.Search(ctx, vector, limit, scoreThreshold, filter)
+	for i := range hits {
+		hits[i].Vector = nil
+	}
+	return hits, err
+}
+
+func (s *vectorStore) SearchWithVectors(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter embedstore.Filter) ([]embedstore.ChunkData, error) {
+	s.withVectors++
+	return s.fakeStore.Search(ctx, vector, limit, scoreThreshold, filter)
+}
+
+func TestRetrieveRequestsVectorsOnlyForMMR(t *testing.T) {
+	for _, tt := range []struct {
+		lambda                float64
+		wantWith, wantWithout int
+	}{
+		{lambda: 0.7, wantWith: 1},
+		{lambda: 0, wantWithout: 1},
+	} {
+		p, embedder, _, _, _ := testPipeline()
+		store := &vectorStore{}
+		p.Store = store
+		p.Diversity = Diversity{Lambda: tt.lambda, MaxPerLink: 3}
+		chunk := embedstore.ChunkData{Link: "a", Text: "cats"}
+		store.Upsert(context.Background(), []embedstore.ChunkData{chunk}, [][]float32{embedder.vector(chunk.Text)})
+
+		got, err := p.Retrieve(context.Background(), "cats")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Errorf("lambda %v: got %d chunks", tt.lambda, len(got))
+		}
+		if store.withVectors != tt.wantWith || store.without != tt.wantWithout {
+			t.Errorf("lambda %v: %d searches with vectors, %d without", tt.lambda, store.withVectors, store.without)
+		}
+		if embedder.docCalls != 0 {
+			t.Errorf("lambda %v: hits re-embedded %d times", tt.lambda, embedder.docCalls)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/chunker"
+	"Audio-LLM-Contextual-Heygen/embedstore"
+	"Audio-LLM-Contextual-Heygen/extract"
+	"Audio-LLM-Contextual-Heygen/search"
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+// Scraper turns a search result into a cleaned article ready for chunking.
+type Scraper interface {
+	Scrape(ctx context.Context, result embedstore.Result) (*extract.Article, error)
+}
+
+// Embedder turns chunks and queries into vectors. Documents and queries are embedded
+// separately so backends with retrieval task types can tell them apart.
+// EmbedDocuments must return one vector per chunk.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, chunks []embedstore.ChunkData) ([][]float32, error)
+	EmbedQuery(ctx context.Context, query string) ([]float32, error)
+	// Name identifies the model; it is stored with every chunk.
+	Name() string
+}
+
+// VectorStore persists embedded chunks and finds the nearest ones to a query vector.
+// Search returns chunks matching filter best first with Score set.
+type VectorStore interface {
+	Upsert(ctx context.Context, chunks []embedstore.ChunkData, vectors [][]float32) error
+	Search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter embedstore.Filter) ([]embedstore.ChunkData, error)
+}
+
+// VectorSearcher is implemented by stores whose Search leaves the stored vectors out
+// of the hits, such as embedstore.QdrantStore. Retrieve asks for them only when
+// diversification will use them, instead of re-embedding the hits.
+type VectorSearcher interface {
+	SearchWithVectors(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter embedstore.Filter) ([]embedstore.ChunkData, error)
+}
+
+// Generator answers a fully assembled prompt.
+type Generator interface {
+	Generate(ctx context.Context, prompt string) (string, error)
+}
+
+// KnowledgeSink records answered queries so other avatars can reuse them.
+type KnowledgeSink interface {
+	Record(ctx context.Context, query, answer string) error
+}
+
+const instruction = `You are a helpful AI assistant that helps users answer queries using the provided context. If you cant frame an answer from the context given, copy paste directly from context rather than making up an answer. Please provide a detailed answer to the query below only using the context provided. Include in-text citations like this [1] for each fact or statement at the end of the sentence. At the end of your response, list all sources in a citation section with the format: [citation number] Name - URL.`
+
+// Pipeline is the search -> scrape -> embed -> store -> retrieve -> generate flow
+// behind /search and the WebSocket handler. Every stage is an interface so it can be
+// swapped or faked.
+type Pipeline struct {
+	Searcher  search.Searcher
+	Scraper   Scraper
+	Embedder  Embedder
+	Store     VectorStore
+	Generator Generator
+	Sink      KnowledgeSink
+
+	Chunker chunker.Splitter
+	// Chunkers overrides Chunker per source type, e.g. semantic chunking for
+	// embedstore.SourceWeb only.
+	Chunkers         map[string]chunker.Splitter
+	MaxChunksPerPage int
+	// TranscriptWindow is the span of media covered by one transcript chunk.
+	TranscriptWindow time.Duration
+	Limit            int
+	ScoreThreshold   float32
+
+	// Session is stamped on every ingested chunk.
+	Session string
+	// Filter restricts which stored chunks Retrieve may return.
+	Filter embedstore.Filter
+	// Keywords, when set, is updated on every Ingest and searched alongside Store.
+	Keywords KeywordIndex
+	Hybrid   Hybrid
+	// Candidates is how many chunks each retriever returns for fusion and
+	// diversification to choose Limit from; zero means 3*Limit.
+	Candidates int
+	// Reranker, when set, reorders the candidates before diversification.
+	Reranker  Reranker
+	Diversity Diversity
+}
+
+// New returns a Pipeline with the defaults the /search handler has always used.
+func New() *Pipeline {
+	return &Pipeline{
+		Chunker:          chunker.New(chunker.Approx, 1000, 100),
+		MaxChunksPerPage: 10,
+		TranscriptWindow: time.Minute,
+		Limit:            10,
+		ScoreThreshold:   0.6,
+		Diversity:        DefaultDiversity,
+	}
+}
+
+// Run answers query end to end: it searches, ingests every result, retrieves the
+// closest chunks, asks the generator and records the answer in the sink.
+func (p *Pipeline) Run(ctx context.Context, query string) (string, error) {
+	results, err := p.Search(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	var wg sync.WaitGroup
+	for _, result := range results {
+		wg.Add(1)
+		go func(result embedstore.Result) {
+			defer wg.Done()
+			if err := p.IngestResult(ctx, result); err != nil {
+				log.Printf("failed to ingest %s: %v", result.Link, err)
+			}
+		}(result)
+	}
+	wg.Wait()
+
+	chunks, err := p.Retrieve(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	return p.Answer(ctx, query, chunks)
+}
+
+// Search returns the candidate pages for query. Use search.Fusion to combine several backends.
+func (p *Pipeline) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	return p.Searcher.Search(ctx, query)
+}
+
+// IngestResult scrapes a single search result and stores its chunks.
+func (p *Pipeline) IngestResult(ctx context.Context, result embedstore.Result) error {
+	article, err := p.Scraper.Scrape(ctx, result)
+	if err != nil {
+		return fmt.Errorf("failed to scrape: %w", err)
+	}
+	if article == nil || article.Text == "" {
+		return nil
+	}
+	title := article.Title
+	if title == "" {
+		title = result.Title
+	}
+	source := embedstore.SourceWeb
+	if result.IsTED {
+		source = embedstore.SourceTED
+	}
+	if len(article.Cues) > 0 {
+		chunks := p.timedChunks(title, result.Link, source, article.Cues)
+		for i := range chunks {
+			chunks[i].Language = article.Language
+		}
+		return p.Ingest(ctx, chunks)
+	}
+
+	texts, err := p.chunkerFor(source).Split(ctx, article.Text)
+	if err != nil {
+		return fmt.Errorf("failed to chunk: %w", err)
+	}
+
+	var chunks []embedstore.ChunkData
+	for _, text := range texts {
+		if len(chunks) >= p.MaxChunksPerPage {
+			break
+		}
+		text = embedstore.SanitizeUTF8(text)
+		if text == "" {
+			continue
+		}
+		chunks = append(chunks, embedstore.ChunkData{
+			Title:    title,
+			Link:     result.Link,
+			Text:     text,
+			Source:   source,
+			Index:    len(chunks),
+			Language: article.Language,
+		})
+	}
+	return p.Ingest(ctx, chunks)
+}
+
+// IngestPDF chunks every page of an uploaded PDF and stores the chunks with their
+// page numbers. It returns the number of chunks stored.
+func (p *Pipeline) IngestPDF(ctx context.Context, name string, doc *extract.PDFDocument) (int, error) {
+	var chunks []embedstore.ChunkData
+	for _, page := range doc.Pages {
+		texts, err := p.chunkerFor(embedstore.SourcePDF).Split(ctx, page.Text)
+		if err != nil {
+			return 0, fmt.Errorf("failed to chunk page %d: %w", page.Number, err)
+		}
+		for _, text := range texts {
+			text = embedstore.SanitizeUTF8(text)
+			if text == "" {
+				continue
+			}
+			chunks = append(chunks, embedstore.ChunkData{
+				Title:  name,
+				Link:   "upload://" + name,
+				Text:   text,
+				Source: embedstore.SourcePDF,
+				Index:  len(chunks),
+				Page:   page.Number,
+			})
+		}
+	}
+	if err := p.Ingest(ctx, chunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// IngestTranscript groups the cues of an uploaded recording into TranscriptWindow
+// sized chunks and stores them with their timestamps. It returns the number of chunks
+// stored.
+func (p *Pipeline) IngestTranscript(ctx context.Context, name string, cues []subtitle.Cue) (int, error) {
+	chunks := p.timedChunks(name, "upload://"+name, embedstore.SourceUpload, cues)
+	if err := p.Ingest(ctx, chunks); err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+func (p *Pipeline) chunkerFor(source string) chunker.Splitter {
+	if c, ok := p.Chunkers[source]; ok {
+		return c
+	}
+	return p.Chunker
+}
+
+func (p *Pipeline) timedChunks(title, link, source string, cues []subtitle.Cue) []embedstore.ChunkData {
+	var chunks []embedstore.ChunkData
+	for _, group := range subtitle.Group(cues, p.TranscriptWindow) {
+		text := embedstore.SanitizeUTF8(group.Text)
+		if text == "" {
+			continue
+		}
+		chunks = append(chunks, embedstore.ChunkData{
+			Title:  title,
+			Link:   link,
+			Text:   text,
+			Source: source,
+			Index:  len(chunks),
+			Start:  group.Start,
+			End:    group.End,
+		})
+	}
+	return chunks
+}
+
+// Ingest embeds the given chunks and upserts them into the vector store, stamped with
+// the embedding model, the session and the ingestion time.
+func (p *Pipeline) Ingest(ctx context.Context, chunks []embedstore.ChunkData) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	vectors, err := p.Embedder.EmbedDocuments(ctx, chunks)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("embedder returned %d vectors for %d chunks", len(vectors), len(chunks))
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := range chunks {
+		chunks[i].Model = p.Embedder.Name()
+		chunks[i].TaskType = embedstore.TaskRetrievalDocument
+		if chunks[i].Session == "" {
+			chunks[i].Session = p.Session
+		}
+		chunks[i].IngestedAt = now
+	}
+
+	if err := p.Store.Upsert(ctx, chunks, vectors); err != nil {
+		return fmt.Errorf("failed to store chunks: %w", err)
+	}
+	if p.Keywords != nil {
+		p.Keywords.Add(chunks)
+	}
+	return nil
+}
+
+// Retrieve embeds the query and returns up to Limit stored chunks that pass Filter.
+// With a KeywordIndex and Hybrid.Mode other than FusionOff, the dense hits are fused
+// with BM25 hits, so chunks sharing rare terms with the query are found even when
+// their vectors fall below ScoreThreshold. The candidates are then reordered by
+// Reranker, if any, and the final chunks picked from them according to Diversity.
+func (p *Pipeline) Retrieve(ctx context.Context, query string) ([]embedstore.ChunkData, error) {
+	vector, err := p.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	hybrid := p.Keywords != nil && p.Hybrid.Mode != FusionOff
+	candidates := p.Limit
+	if hybrid || p.Reranker != nil || p.Diversity != (Diversity{}) {
+		candidates = p.Candidates
+		if candidates <= 0 {
+			candidates = 3 * p.Limit
+		}
+	}
+
+	search := p.Store.Search
+	if vs, ok := p.Store.(VectorSearcher); ok && p.Diversity.Lambda > 0 {
+		search = vs.SearchWithVectors
+	}
+	chunks, err := search(ctx, vector, candidates, p.ScoreThreshold, p.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search vector store: %w", err)
+	}
+	for _, chunk := range chunks {
+		if chunk.Model != "" && chunk.Model != p.Embedder.Name() {
+			log.Printf("vector store holds %s embeddings but queries use %s; scores are not comparable", chunk.Model, p.Embedder.Name())
+			break
+		}
+	}
+	if hybrid {
+		chunks = fuse(chunks, p.Keywords.Search(query, candidates, p.Filter), p.Hybrid, candidates)
+	}
+	if p.Reranker != nil {
+		reranked, err := p.Reranker.Rerank(ctx, query, chunks)
+		if err != nil {
+			log.Printf("skipping reranking: %v", err)
+		} else {
+			chunks = reranked
+		}
+	}
+
+	diverse, err := p.diversify(ctx, chunks, p.Limit)
+	if err != nil {
+		log.Printf("skipping diversification: %v", err)
+		return capPerLink(chunks, p.Diversity.MaxPerLink, p.Limit), nil
+	}
+	return diverse, nil
+}
+
+// Answer builds the prompt from the retrieved chunks, generates the answer and hands
+// it to the knowledge sink. Sink failures are logged, not returned.
+func (p *Pipeline) Answer(ctx context.Context, query string, chunks []embedstore.ChunkData) (string, error) {
+	answer, err := p.Generator.Generate(ctx, BuildPrompt(query, chunks))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate answer: %w", err)
+	}
+
+	if p.Sink != nil {
+		if err := p.Sink.Record(ctx, query, answer); err != nil {
+			log.Printf("failed to record answer: %v", err)
+		}
+	}
+	return answer, nil
+}
+
+// BuildPrompt combines the instruction, query and retrieved chunks into the prompt sent to the LLM.
+func BuildPrompt(query string, chunks []embedstore.ChunkData) string {
+	var sb strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Text == query {
+			continue
+		}
+		sb.WriteString("Title of the website where the following paragraph was obtained from -> " + chunk.Citation() + ". Link of the website -> " + chunk.Link + " . Paragraph -> " + chunk.Text + " . End of that paragraph.\n Starting new paragraph :  \n")
+	}
+	return "INSTRUCTION : " + instruction + ". QUERY : " + query + ". CONTEXT : " + sb.String() + "."
+}
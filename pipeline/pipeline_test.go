@@ -0,0 +1,518 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"Audio-LLM-Contextual-Heygen/chunker"
+	"Audio-LLM-Contextual-Heygen/embedstore"
+	"Audio-LLM-Contextual-Heygen/extract"
+)
+
+type fakeSearcher struct {
+	results []embedstore.Result
+	err     error
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	return s.results, s.err
+}
+
+// fakeScraper serves articles by link; links in errs fail.
+type fakeScraper struct {
+	articles map[string]*extract.Article
+	errs     map[string]error
+}
+
+func (s *fakeScraper) Scrape(ctx context.Context, result embedstore.Result) (*extract.Article, error) {
+	if err := s.errs[result.Link]; err != nil {
+		return nil, err
+	}
+	return s.articles[result.Link], nil
+}
+
+// vocabulary gives fakeEmbedder its dimensions: a text's vector counts these words.
+var vocabulary = []string{"cats", "rockets", "music", "weather"}
+
+type fakeEmbedder struct {
+	docErr, queryErr error
+	// short drops the last vector of every EmbedDocuments call.
+	short bool
+
+	mu       sync.Mutex
+	docCalls int
+}
+
+func (e *fakeEmbedder) vector(text string) []float32 {
+	text = strings.ToLower(text)
+	v := make([]float32, len(vocabulary))
+	for i, word := range vocabulary {
+		v[i] = float32(strings.Count(text, word))
+	}
+	return v
+}
+
+func (e *fakeEmbedder) EmbedDocuments(ctx context.Context, chunks []embedstore.ChunkData) ([][]float32, error) {
+	e.mu.Lock()
+	e.docCalls++
+	e.mu.Unlock()
+	if e.docErr != nil {
+		return nil, e.docErr
+	}
+	vectors := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		vectors[i] = e.vector(chunk.Text)
+	}
+	if e.short {
+		vectors = vectors[:len(vectors)-1]
+	}
+	return vectors, nil
+}
+
+func (e *fakeEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	if e.queryErr != nil {
+		return nil, e.queryErr
+	}
+	return e.vector(query), nil
+}
+
+func (e *fakeEmbedder) Name() string { return "fake" }
+
+// fakeStore keeps upserted chunks in memory and scores them by cosine similarity.
+type fakeStore struct {
+	upsertErr, searchErr error
+
+	mu      sync.Mutex
+	chunks  []embedstore.ChunkData
+	vectors [][]float32
+	// filter and limit record the last Search.
+	filter embedstore.Filter
+	limit  int
+}
+
+func (s *fakeStore) Upsert(ctx context.Context, chunks []embedstore.ChunkData, vectors [][]float32) error {
+	if s.upsertErr != nil {
+		return s.upsertErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks = append(s.chunks, chunks...)
+	s.vectors = append(s.vectors, vectors...)
+	return nil
+}
+
+func (s *fakeStore) Search(ctx context.Context, vector []float32, limit int, scoreThreshold float32, filter embedstore.Filter) ([]embedstore.ChunkData, error) {
+	if s.searchErr != nil {
+		return nil, s.searchErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter, s.limit = filter, limit
+	var hits []embedstore.ChunkData
+	for i, chunk := range s.chunks {
+		score := float32(cosine(vector, s.vectors[i]))
+		if score < scoreThreshold || !filter.Match(chunk) {
+			continue
+		}
+		chunk.Score, chunk.Vector = score, s.vectors[i]
+		hits = append(hits, chunk)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+type fakeGenerator struct {
+	answer string
+	err    error
+	prompt string
+}
+
+func (g *fakeGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	g.prompt = prompt
+	return g.answer, g.err
+}
+
+type fakeSink struct {
+	err     error
+	records []string
+}
+
+func (s *fakeSink) Record(ctx context.Context, query, answer string) error {
+	s.records = append(s.records, query+" => "+answer)
+	return s.err
+}
+
+// captureLog redirects the standard logger for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func testPipeline() (*Pipeline, *fakeEmbedder, *fakeStore, *fakeGenerator, *fakeSink) {
+	embedder, store, generator, sink := &fakeEmbedder{}, &fakeStore{}, &fakeGenerator{answer: "the answer"}, &fakeSink{}
+	p := New()
+	p.Embedder, p.Store, p.Generator, p.Sink = embedder, store, generator, sink
+	p.Chunker = chunker.New(chunker.Approx, 8, 0)
+	p.ScoreThreshold = 0.5
+	return p, embedder, store, generator, sink
+}
+
+var (
+	catsPage    = &extract.Article{Title: "Cats", Text: "Cats sleep all day.\n\nCats purr at night."}
+	rocketsPage = &extract.Article{Title: "Rockets", Text: "Rockets reach orbit.\n\nRockets burn fuel."}
+)
+
+func TestRun(t *testing.T) {
+	results := []embedstore.Result{{Title: "Cats", Link: "https://cats.example"}, {Title: "Rockets", Link: "https://rockets.example"}}
+	tests := []struct {
+		name  string
+		setup func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher)
+		// wantErr is a substring of the returned error; empty means success.
+		wantErr string
+		// wantPrompt and notPrompt are checked against the generator's prompt.
+		wantPrompt, notPrompt []string
+		wantLog               string
+		wantRecords           int
+	}{
+		{
+			name:        "answers from ingested pages",
+			wantPrompt:  []string{"QUERY : how do cats sleep", "Cats sleep all day.", "https://cats.example"},
+			notPrompt:   []string{"Rockets"},
+			wantRecords: 1,
+		},
+		{
+			name: "scrape failure skips the page",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				sc.errs = map[string]error{"https://rockets.example": errors.New("403 forbidden")}
+			},
+			wantPrompt:  []string{"Cats sleep all day."},
+			wantLog:     "failed to ingest https://rockets.example: failed to scrape: 403 forbidden",
+			wantRecords: 1,
+		},
+		{
+			name: "embed failure leaves nothing to retrieve",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				e.docErr = errors.New("quota exceeded")
+			},
+			notPrompt:   []string{"Cats sleep all day."},
+			wantLog:     "failed to embed chunks: quota exceeded",
+			wantRecords: 1,
+		},
+		{
+			name: "sink failure is only logged",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				k.err = errors.New("sink down")
+			},
+			wantPrompt:  []string{"Cats sleep all day."},
+			wantLog:     "failed to record answer: sink down",
+			wantRecords: 1,
+		},
+		{
+			name: "search failure",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				se.err = errors.New("searx unavailable")
+			},
+			wantErr: "searx unavailable",
+		},
+		{
+			name: "query embed failure",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				e.queryErr = errors.New("quota exceeded")
+			},
+			wantErr: "failed to embed query: quota exceeded",
+		},
+		{
+			name: "generator failure is not recorded",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore, g *fakeGenerator, k *fakeSink, sc *fakeScraper, se *fakeSearcher) {
+				g.err = errors.New("model overloaded")
+			},
+			wantErr: "failed to generate answer: model overloaded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			p, embedder, store, generator, sink := testPipeline()
+			scraper := &fakeScraper{articles: map[string]*extract.Article{
+				"https://cats.example":    catsPage,
+				"https://rockets.example": rocketsPage,
+			}}
+			searcher := &fakeSearcher{results: results}
+			p.Searcher, p.Scraper = searcher, scraper
+			if tt.setup != nil {
+				tt.setup(p, embedder, store, generator, sink, scraper, searcher)
+			}
+
+			answer, err := p.Run(context.Background(), "how do cats sleep")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run error = %v, want %q", err, tt.wantErr)
+				}
+				if len(sink.records) != 0 {
+					t.Errorf("sink recorded %q after a failure", sink.records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if answer != "the answer" {
+				t.Errorf("answer = %q", answer)
+			}
+			for _, s := range tt.wantPrompt {
+				if !strings.Contains(generator.prompt, s) {
+					t.Errorf("prompt lacks %q:\n%s", s, generator.prompt)
+				}
+			}
+			for _, s := range tt.notPrompt {
+				if strings.Contains(generator.prompt, s) {
+					t.Errorf("prompt contains %q:\n%s", s, generator.prompt)
+				}
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log lacks %q:\n%s", tt.wantLog, logs.String())
+			}
+			if len(sink.records) != tt.wantRecords {
+				t.Errorf("sink got %d records, want %d", len(sink.records), tt.wantRecords)
+			}
+		})
+	}
+}
+
+func TestIngest(t *testing.T) {
+	chunks := func() []embedstore.ChunkData {
+		return []embedstore.ChunkData{
+			{Title: "Cats", Link: "https://cats.example", Text: "cats purr", Index: 0},
+			{Title: "Cats", Link: "https://cats.example", Text: "cats sleep", Index: 1, Session: "other"},
+		}
+	}
+	tests := []struct {
+		name    string
+		chunks  []embedstore.ChunkData
+		setup   func(e *fakeEmbedder, s *fakeStore)
+		wantErr string
+		// wantStored is how many chunks must reach both the store and the keyword index.
+		wantStored int
+	}{
+		{name: "stores and indexes", chunks: chunks(), wantStored: 2},
+		{name: "nothing to ingest", chunks: nil},
+		{
+			name:    "embed failure",
+			chunks:  chunks(),
+			setup:   func(e *fakeEmbedder, s *fakeStore) { e.docErr = errors.New("quota exceeded") },
+			wantErr: "failed to embed chunks: quota exceeded",
+		},
+		{
+			name:    "missing vectors",
+			chunks:  chunks(),
+			setup:   func(e *fakeEmbedder, s *fakeStore) { e.short = true },
+			wantErr: "embedder returned 1 vectors for 2 chunks",
+		},
+		{
+			name:    "store failure",
+			chunks:  chunks(),
+			setup:   func(e *fakeEmbedder, s *fakeStore) { s.upsertErr = errors.New("qdrant down") },
+			wantErr: "failed to store chunks: qdrant down",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, embedder, store, _, _ := testPipeline()
+			p.Session = "session-1"
+			keywords := embedstore.NewKeywordIndex()
+			p.Keywords = keywords
+			if tt.setup != nil {
+				tt.setup(embedder, store)
+			}
+
+			err := p.Ingest(context.Background(), tt.chunks)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Ingest error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Ingest: %v", err)
+			}
+			if len(tt.chunks) == 0 && embedder.docCalls != 0 {
+				t.Errorf("embedder called for no chunks")
+			}
+			if len(store.chunks) != tt.wantStored || keywords.Len() != tt.wantStored {
+				t.Fatalf("stored %d chunks and indexed %d, want %d", len(store.chunks), keywords.Len(), tt.wantStored)
+			}
+			for i, chunk := range store.chunks {
+				if chunk.Model != "fake" || chunk.TaskType != embedstore.TaskRetrievalDocument || chunk.IngestedAt.IsZero() {
+					t.Errorf("chunk %d not stamped: %+v", i, chunk)
+				}
+				if want := []string{"session-1", "other"}[i]; chunk.Session != want {
+					t.Errorf("chunk %d session = %q, want %q", i, chunk.Session, want)
+				}
+			}
+		})
+	}
+}
+
+// failingReranker always fails, so Retrieve must keep the retrieval order.
+type failingReranker struct{}
+
+func (failingReranker) Rerank(ctx context.Context, query string, chunks []embedstore.ChunkData) ([]embedstore.ChunkData, error) {
+	return nil, errors.New("reranker down")
+}
+
+func TestRetrieve(t *testing.T) {
+	stored := []embedstore.ChunkData{
+		{Title: "Cats", Link: "https://cats.example", Text: "cats cats cats", Source: embedstore.SourceWeb, Model: "fake"},
+		{Title: "Cats", Link: "https://cats.example", Text: "cats and music", Source: embedstore.SourceWeb, Index: 1, Model: "fake"},
+		{Title: "Rockets", Link: "upload://rockets.pdf", Text: "rockets and cats", Source: embedstore.SourcePDF, Model: "fake"},
+		{Title: "Weather", Link: "https://weather.example", Text: "weather report XJ-9", Source: embedstore.SourceWeb, Model: "other"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		setup   func(p *Pipeline, e *fakeEmbedder, s *fakeStore)
+		want    []string // texts, best first
+		wantErr string
+		wantLog string
+	}{
+		{
+			name:  "best match first",
+			query: "cats",
+			want:  []string{"cats cats cats", "cats and music", "rockets and cats"},
+		},
+		{
+			name:  "limit",
+			query: "cats",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore) { p.Limit = 1 },
+			want:  []string{"cats cats cats"},
+		},
+		{
+			name:  "filter",
+			query: "cats",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore) {
+				p.Filter = embedstore.Filter{Sources: []string{embedstore.SourcePDF}}
+			},
+			want: []string{"rockets and cats"},
+		},
+		{
+			name:  "per-link cap",
+			query: "cats",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore) {
+				p.Diversity = Diversity{MaxPerLink: 1}
+			},
+			want: []string{"cats cats cats", "rockets and cats"},
+		},
+		{
+			name:  "hybrid finds exact terms below the threshold",
+			query: "XJ-9",
+			setup: func(p *Pipeline, e *fakeEmbedder, s *fakeStore) {
+				p.Keywords = embedstore.NewKeywordIndex()
+				p.Keywords.Add(stored)
+			},
+			want: []string{"weather report XJ-9"},
+		},
+		{
+			name:  "model mismatch is logged",
+			query: "weather",
+			want:  []string{"weather report XJ-9"},
+			// The query vector only matches the chunk embedded by another model.
+			wantLog: "vector store holds other embeddings but queries use fake",
+		},
+		{
+			name:    "reranker failure keeps retrieval order",
+			query:   "cats",
+			setup:   func(p *Pipeline, e *fakeEmbedder, s *fakeStore) { p.Reranker = failingReranker{} },
+			want:    []string{"cats cats cats", "cats and music", "rockets and cats"},
+			wantLog: "skipping reranking: reranker down",
+		},
+		{
+			name:    "query embed failure",
+			query:   "cats",
+			setup:   func(p *Pipeline, e *fakeEmbedder, s *fakeStore) { e.queryErr = errors.New("quota exceeded") },
+			wantErr: "failed to embed query: quota exceeded",
+		},
+		{
+			name:    "store failure",
+			query:   "cats",
+			setup:   func(p *Pipeline, e *fakeEmbedder, s *fakeStore) { s.searchErr = errors.New("qdrant down") },
+			wantErr: "failed to search vector store: qdrant down",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			p, embedder, store, _, _ := testPipeline()
+			p.Diversity = Diversity{}
+			for _, chunk := range stored {
+				store.Upsert(context.Background(), []embedstore.ChunkData{chunk}, [][]float32{embedder.vector(chunk.Text)})
+			}
+			if tt.setup != nil {
+				tt.setup(p, embedder, store)
+			}
+
+			chunks, err := p.Retrieve(context.Background(), tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Retrieve error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			var got []string
+			for _, chunk := range chunks {
+				got = append(got, chunk.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if len(store.filter.Sources) != len(p.Filter.Sources) {
+				t.Errorf("filter not passed to the store")
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log lacks %q:\n%s", tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+// TestOfflineIngestRetrieve runs Ingest and Retrieve with the offline embedder and the
+// in-memory store instead of fakes.
+func TestOfflineIngestRetrieve(t *testing.T) {
+	ctx := context.Background()
+	p := New()
+	p.Embedder = embedstore.NewHashEmbedder(256)
+	p.Store = embedstore.NewMemoryStore()
+	p.ScoreThreshold = 0
+	p.Limit = 2
+
+	chunks := []embedstore.ChunkData{
+		{Title: "Whales", Link: "https://example.com/whales", Text: "Blue whales are the largest animals ever known to have lived on Earth."},
+		{Title: "Coffee", Link: "https://example.com/coffee", Text: "Espresso is brewed by forcing hot water through finely ground coffee beans."},
+		{Title: "Rockets", Link: "https://example.com/rockets", Text: "A rocket engine burns propellant to produce thrust and reach orbit."},
+	}
+	if err := p.Ingest(ctx, chunks); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Retrieve(ctx, "what does a rocket engine burn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || got[0].Link != "https://example.com/rockets" {
+		t.Fatalf("got %+v, want the rockets chunk first", got)
+	}
+	if got[0].Model != "hash-256" {
+		t.Errorf("retrieved chunk model = %q", got[0].Model)
+	}
+}
@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"Audio-LLM-Contextual-Heygen/bm25"
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// Reranker reorders retrieved chunks by their relevance to the query. It returns the
+// same chunks, best first, with Score replaced by its own relevance score.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, chunks []embedstore.ChunkData) ([]embedstore.ChunkData, error)
+}
+
+// rerankByScores sorts chunks by scores, keeping the retrieval order for ties.
+func rerankByScores(chunks []embedstore.ChunkData, scores []float64) []embedstore.ChunkData {
+	ranked := make([]embedstore.ChunkData, len(chunks))
+	copy(ranked, chunks)
+	for i := range ranked {
+		ranked[i].Score = float32(scores[i])
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	return ranked
+}
+
+// LLMReranker asks the Generator to grade every chunk against the query in a single
+// prompt, so reranking costs one extra model call per question.
+type LLMReranker struct {
+	Generator Generator
+	// MaxChars truncates each chunk in the grading prompt; zero means 1000.
+	MaxChars int
+}
+
+func NewLLMReranker(generator Generator) *LLMReranker {
+	return &LLMReranker{Generator: generator, MaxChars: 1000}
+}
+
+const rerankInstruction = `Rate how relevant each passage is to the query, from 0 (unrelated) to 10 (answers it directly). Reply with only a JSON array of numbers, one per passage, in passage order, e.g. [7, 0, 3].`
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, chunks []embedstore.ChunkData) ([]embedstore.ChunkData, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+	maxChars := r.MaxChars
+	if maxChars <= 0 {
+		maxChars = 1000
+	}
+
+	var sb strings.Builder
+	sb.WriteString(rerankInstruction + "\n\nQuery: " + query + "\n")
+	for i, chunk := range chunks {
+		text := []rune(chunk.Text)
+		if len(text) > maxChars {
+			text = text[:maxChars]
+		}
+		fmt.Fprintf(&sb, "\nPassage %d (%s): %s\n", i+1, chunk.Title, strings.Join(strings.Fields(string(text)), " "))
+	}
+
+	reply, err := r.Generator.Generate(ctx, sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to grade chunks: %w", err)
+	}
+	scores, err := parseGrades(reply, len(chunks))
+	if err != nil {
+		return nil, err
+	}
+	return rerankByScores(chunks, scores), nil
+}
+
+// parseGrades reads the JSON array of n grades out of reply, which may wrap it in
+// prose or a code fence, and scales them to 0..1. Grades outside 0..10 are clamped.
+func parseGrades(reply string, n int) ([]float64, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no grades in reranker reply %q", reply)
+	}
+	var grades []float64
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &grades); err != nil {
+		return nil, fmt.Errorf("invalid grades in reranker reply %q: %w", reply, err)
+	}
+	if len(grades) != n {
+		return nil, fmt.Errorf("reranker graded %d of %d chunks", len(grades), n)
+	}
+	for i := range grades {
+		grades[i] = min(max(grades[i], 0), 10) / 10
+	}
+	return grades, nil
+}
+
+// LexicalReranker scores chunks by how much of the query they contain: the share of
+// distinct query terms found in the chunk's title or text, plus half the share of
+// query bigrams found in order. It needs no model and suits offline runs.
+type LexicalReranker struct{}
+
+func (LexicalReranker) Rerank(ctx context.Context, query string, chunks []embedstore.ChunkData) ([]embedstore.ChunkData, error) {
+	terms := bm25.Tokenize(query)
+	if len(terms) == 0 {
+		return chunks, nil
+	}
+	queryTerms := make(map[string]bool)
+	for _, t := range terms {
+		queryTerms[t] = true
+	}
+	queryBigrams := make(map[string]bool)
+	for i := 1; i < len(terms); i++ {
+		queryBigrams[terms[i-1]+" "+terms[i]] = true
+	}
+
+	scores := make([]float64, len(chunks))
+	for i, chunk := range chunks {
+		words := bm25.Tokenize(chunk.Title + " " + chunk.Text)
+		seen := make(map[string]bool)
+		for j, w := range words {
+			if queryTerms[w] {
+				seen[w] = true
+			}
+			if j > 0 && queryBigrams[words[j-1]+" "+w] {
+				seen[words[j-1]+" "+w] = true
+			}
+		}
+		var termHits, bigramHits int
+		for key := range seen {
+			if strings.Contains(key, " ") {
+				bigramHits++
+			} else {
+				termHits++
+			}
+		}
+		scores[i] = float64(termHits) / float64(len(queryTerms))
+		if len(queryBigrams) > 0 {
+			scores[i] += 0.5 * float64(bigramHits) / float64(len(queryBigrams))
+		}
+	}
+	return rerankByScores(chunks, scores), nil
+}
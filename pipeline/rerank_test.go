@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+func TestParseGrades(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		n       int
+		want    []float64
+		wantErr string
+	}{
+		{name: "bare array", reply: "[7, 0, 10]", n: 3, want: []float64{0.7, 0, 1}},
+		{name: "code fence", reply: "```json\n[3, 5.5]\n```", n: 2, want: []float64{0.3, 0.55}},
+		{name: "prose", reply: "Here are the grades: [8, 2]. Passage 1 answers it.", n: 2, want: []float64{0.8, 0.2}},
+		{name: "clamped", reply: "[12, -3, 100]", n: 3, want: []float64{1, 0, 1}},
+		{name: "too few", reply: "[1, 2]", n: 3, wantErr: "graded 2 of 3"},
+		{name: "too many", reply: "[1, 2, 3, 4]", n: 3, wantErr: "graded 4 of 3"},
+		{name: "no array", reply: "All passages look relevant.", n: 2, wantErr: "no grades"},
+		{name: "not numbers", reply: `["high", "low"]`, n: 2, wantErr: "invalid grades"},
+		{name: "brackets in prose", reply: "Grades [see below]: [1, 2]", n: 2, wantErr: "invalid grades"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGrades(tt.reply, tt.n)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if diff := got[i] - tt.want[i]; diff > 1e-9 || diff < -1e-9 {
+					t.Errorf("got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLLMReranker(t *testing.T) {
+	chunks := []embedstore.ChunkData{{Title: "A", Text: "first"}, {Title: "B", Text: "second"}, {Title: "C", Text: "third"}}
+
+	g := &fakeGenerator{answer: "[2, 9, 2]"}
+	got, err := NewLLMReranker(g).Rerank(context.Background(), "query", chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(got) != "second,first,third" || got[0].Score != 0.9 {
+		t.Errorf("got %s with top score %v", texts(got), got[0].Score)
+	}
+	if !strings.Contains(g.prompt, "Passage 3 (C): third") {
+		t.Errorf("prompt lacks the passages:\n%s", g.prompt)
+	}
+
+	g.err = errors.New("model overloaded")
+	if _, err := NewLLMReranker(g).Rerank(context.Background(), "query", chunks); err == nil {
+		t.Error("generator failure not reported")
+	}
+}
+
+func TestLexicalReranker(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		chunks []embedstore.ChunkData
+		want   string
+		scores []float32
+	}{
+		{
+			name:  "coverage and bigrams",
+			query: "solar panel efficiency",
+			chunks: []embedstore.ChunkData{
+				{Text: "Efficiency of wind turbines."},
+				{Text: "Panel efficiency depends on the solar angle."},
+				{Text: "Solar panel efficiency keeps improving."},
+			},
+			// 1/3; 3/3 + 0.5*1/2; 3/3 + 0.5*2/2.
+			want:   "Solar panel efficiency keeps improving.,Panel efficiency depends on the solar angle.,Efficiency of wind turbines.",
+			scores: []float32{1.5, 1.25, 1.0 / 3},
+		},
+		{
+			name:   "title counts",
+			query:  "whales",
+			chunks: []embedstore.ChunkData{{Text: "Nothing here."}, {Title: "Whales", Text: "Large mammals."}},
+			want:   "Large mammals.,Nothing here.",
+			scores: []float32{1, 0},
+		},
+		{
+			name:   "ties keep retrieval order",
+			query:  "unrelated",
+			chunks: []embedstore.ChunkData{{Text: "one"}, {Text: "two"}},
+			want:   "one,two",
+			scores: []float32{0, 0},
+		},
+		{
+			name:   "empty query keeps everything",
+			query:  "   ",
+			chunks: []embedstore.ChunkData{{Text: "one", Score: 0.4}, {Text: "two", Score: 0.9}},
+			want:   "one,two",
+			scores: []float32{0.4, 0.9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LexicalReranker{}.Rerank(context.Background(), tt.query, tt.chunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if texts(got) != tt.want {
+				t.Errorf("got %s, want %s", texts(got), tt.want)
+			}
+			for i := range got {
+				if diff := got[i].Score - tt.scores[i]; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("chunk %d score = %v, want %v", i, got[i].Score, tt.scores[i])
+				}
+			}
+		})
+	}
+}
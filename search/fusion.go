@@ -0,0 +1,185 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// DefaultRRFK is the rank constant from the original reciprocal-rank fusion paper.
+const DefaultRRFK = 60
+
+// trackingParams are query parameters that never change the content of a page.
+var trackingParams = map[string]struct{}{
+	"gclid": {}, "dclid": {}, "fbclid": {}, "msclkid": {}, "yclid": {}, "igshid": {},
+	"mc_cid": {}, "mc_eid": {}, "ref": {}, "ref_src": {}, "_ga": {}, "_gl": {}, "si": {},
+}
+
+// CanonicalURL normalizes a link for de-duplication: scheme and host are lowercased,
+// http and https are treated alike, "www." and default ports are dropped, the fragment
+// and tracking parameters (utm_*, gclid, fbclid, ...) are removed, the remaining
+// parameters are sorted and trailing slashes are trimmed. Unparseable links are
+// returned trimmed but otherwise unchanged.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.TrimRight(raw, "/")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" {
+		scheme = "https"
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	q := u.Query()
+	for key := range q {
+		lower := strings.ToLower(key)
+		if _, ok := trackingParams[lower]; ok || strings.HasPrefix(lower, "utm_") {
+			q.Del(key)
+		}
+	}
+
+	canonical := scheme + "://" + host + strings.TrimRight(u.EscapedPath(), "/")
+	if encoded := q.Encode(); encoded != "" {
+		canonical += "?" + encoded
+	}
+	return canonical
+}
+
+// Source is a named Searcher taking part in a Fusion. The name is recorded in
+// Result.Sources for every result the searcher contributed.
+type Source struct {
+	Name     string
+	Searcher Searcher
+	// Weight scales the source's RRF contribution; zero means 1.
+	Weight float64
+}
+
+// Fusion runs several searchers concurrently and merges their rankings with
+// reciprocal-rank fusion. Results pointing at the same canonical URL are merged into
+// one, keeping the IsTED flag if any source set it and the union of their sources.
+type Fusion struct {
+	Sources []Source
+	// K is the RRF rank constant; zero means DefaultRRFK.
+	K float64
+	// MaxResults caps the fused list; zero keeps every result.
+	MaxResults int
+}
+
+func NewFusion(sources ...Source) *Fusion {
+	return &Fusion{Sources: sources, K: DefaultRRFK}
+}
+
+type fused struct {
+	result    embedstore.Result
+	canonical string
+	bestRank  int
+}
+
+// Search fails only if every source fails; otherwise failing sources are logged and skipped.
+func (f *Fusion) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	k := f.K
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+
+	var (
+		mu     sync.Mutex
+		merged = make(map[string]*fused)
+		errs   []string
+		wg     sync.WaitGroup
+	)
+	for _, source := range f.Sources {
+		wg.Add(1)
+		go func(source Source) {
+			defer wg.Done()
+			results, err := source.Searcher.Search(ctx, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("search source %s failed: %v", source.Name, err)
+				errs = append(errs, fmt.Sprintf("%s: %v", source.Name, err))
+			}
+
+			weight := source.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			// A source can list the same page twice (tracking parameters, http and
+			// https); it is credited once, at its best rank.
+			seen := make(map[string]struct{}, len(results))
+			for rank, result := range results {
+				if result.Link == "" {
+					continue
+				}
+				key := CanonicalURL(result.Link)
+				if _, dup := seen[key]; dup {
+					continue
+				}
+				seen[key] = struct{}{}
+				score := weight / (k + float64(rank+1))
+
+				m, ok := merged[key]
+				if !ok {
+					result.Sources = nil
+					result.Score = 0
+					m = &fused{result: result, canonical: key, bestRank: rank}
+					merged[key] = m
+				} else if rank < m.bestRank {
+					// Prefer the title and link from the source that ranked it highest.
+					m.result.Title, m.result.Link, m.bestRank = result.Title, result.Link, rank
+				}
+				m.result.Score += score
+				m.result.IsTED = m.result.IsTED || result.IsTED
+				m.result.Sources = appendSource(m.result.Sources, source.Name)
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 && len(errs) == len(f.Sources) {
+		return nil, fmt.Errorf("all search sources failed: %s", strings.Join(errs, "; "))
+	}
+
+	all := make([]*fused, 0, len(merged))
+	for _, m := range merged {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].result.Score != all[j].result.Score {
+			return all[i].result.Score > all[j].result.Score
+		}
+		return all[i].canonical < all[j].canonical
+	})
+
+	results := make([]embedstore.Result, 0, len(all))
+	for _, m := range all {
+		sort.Strings(m.result.Sources)
+		results = append(results, m.result)
+	}
+	if f.MaxResults > 0 && len(results) > f.MaxResults {
+		results = results[:f.MaxResults]
+	}
+	return results, nil
+}
+
+func appendSource(sources []string, name string) []string {
+	for _, s := range sources {
+		if s == name {
+			return sources
+		}
+	}
+	return append(sources, name)
+}
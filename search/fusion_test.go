@@ -0,0 +1,154 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// staticSearcher returns the same results, or error, for every query.
+type staticSearcher struct {
+	results []embedstore.Result
+	err     error
+}
+
+func (s staticSearcher) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	return s.results, s.err
+}
+
+func links(links ...string) staticSearcher {
+	var s staticSearcher
+	for _, link := range links {
+		s.results = append(s.results, embedstore.Result{Title: link, Link: link})
+	}
+	return s
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/page", "https://example.com/page"},
+		{"http://example.com/page", "https://example.com/page"},
+		{"HTTPS://WWW.Example.COM/Page/", "https://example.com/Page"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:80/a", "https://example.com/a"},
+		{"https://example.com:8080/a", "https://example.com:8080/a"},
+		{"https://example.com/a#section", "https://example.com/a"},
+		{"https://example.com/a?utm_source=x&UTM_Medium=y&gclid=1&fbclid=2", "https://example.com/a"},
+		{"https://example.com/a?b=2&a=1&ref=feed", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/a%20b", "https://example.com/a%20b"},
+		{"https://example.com/", "https://example.com"},
+		{"  not a url/ ", "not a url"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CanonicalURL(tt.in); got != tt.want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func rrf(ranks ...int) float64 {
+	var score float64
+	for _, rank := range ranks {
+		score += 1 / float64(DefaultRRFK+rank)
+	}
+	return score
+}
+
+func TestFusionOrder(t *testing.T) {
+	f := NewFusion(
+		Source{Name: "a", Searcher: links("https://x.com", "https://y.com", "https://z.com")},
+		Source{Name: "b", Searcher: links("http://www.y.com/", "https://w.com")},
+	)
+	results, err := f.Search(context.Background(), "query")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	want := []struct {
+		link    string
+		score   float64
+		sources []string
+	}{
+		{"https://y.com", rrf(2, 1), []string{"a", "b"}},
+		{"https://x.com", rrf(1), []string{"a"}},
+		{"https://w.com", rrf(2), []string{"b"}},
+		{"https://z.com", rrf(3), []string{"a"}},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results %+v, want %d", len(results), results, len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if CanonicalURL(r.Link) != w.link || math.Abs(r.Score-w.score) > 1e-12 || !reflect.DeepEqual(r.Sources, w.sources) {
+			t.Errorf("result %d = %s %.5f %v, want %s %.5f %v", i, r.Link, r.Score, r.Sources, w.link, w.score, w.sources)
+		}
+	}
+	// The merged result keeps the link of the source that ranked it highest.
+	if results[0].Link != "http://www.y.com/" {
+		t.Errorf("merged link = %q, want the one from source b", results[0].Link)
+	}
+}
+
+func TestFusionCountsDuplicatesOnce(t *testing.T) {
+	f := NewFusion(
+		Source{Name: "a", Searcher: links(
+			"https://x.com/page",
+			"http://x.com/page?utm_source=feed",
+			"https://y.com",
+			"https://x.com/page/#top",
+		)},
+		Source{Name: "b", Searcher: links("https://y.com")},
+	)
+	results, err := f.Search(context.Background(), "query")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results %+v, want 2", len(results), results)
+	}
+	// Counting x three times would put it first; once, it trails y, which both
+	// sources agree on.
+	if results[0].Link != "https://y.com" || math.Abs(results[0].Score-rrf(3, 1)) > 1e-12 {
+		t.Errorf("first result = %s %.5f, want https://y.com %.5f", results[0].Link, results[0].Score, rrf(3, 1))
+	}
+	if results[1].Link != "https://x.com/page" || math.Abs(results[1].Score-rrf(1)) > 1e-12 {
+		t.Errorf("second result = %s %.5f, want https://x.com/page %.5f", results[1].Link, results[1].Score, rrf(1))
+	}
+}
+
+func TestFusionSourcesAndLimits(t *testing.T) {
+	ted := links("https://ted.com/talks/a")
+	ted.results[0].IsTED = true
+	f := NewFusion(
+		Source{Name: "web", Searcher: links("https://ted.com/talks/a", "https://b.com", "https://c.com")},
+		Source{Name: "ted", Searcher: ted, Weight: 2},
+		Source{Name: "broken", Searcher: staticSearcher{err: errors.New("boom")}},
+	)
+	f.MaxResults = 2
+	results, err := f.Search(context.Background(), "query")
+	if err != nil {
+		t.Fatalf("Search with one failing source: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want MaxResults 2", len(results))
+	}
+	if r := results[0]; !r.IsTED || math.Abs(r.Score-(rrf(1)+2*rrf(1))) > 1e-12 || !reflect.DeepEqual(r.Sources, []string{"ted", "web"}) {
+		t.Errorf("first result = %+v, want the weighted TED talk from both sources", r)
+	}
+
+	f = NewFusion(
+		Source{Name: "one", Searcher: staticSearcher{err: errors.New("down")}},
+		Source{Name: "two", Searcher: staticSearcher{err: errors.New("timeout")}},
+	)
+	if _, err := f.Search(context.Background(), "query"); err == nil || !strings.Contains(err.Error(), "all search sources failed") {
+		t.Errorf("Search error = %v, want all sources failed", err)
+	}
+}
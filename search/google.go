@@ -0,0 +1,141 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+const (
+	GoogleSearchURL = "https://www.googleapis.com/customsearch/v1"
+
+	// Custom Search returns at most 10 items per page and refuses start > 91.
+	googlePageSize = 10
+	googleMaxStart = 91
+)
+
+// Google queries the Google Custom Search JSON API. The same type serves plain web
+// search and TED search; the latter is just a query prefix plus IsTED tagging.
+type Google struct {
+	APIKey      string
+	CX          string
+	QueryPrefix string
+	MaxResults  int
+	IsTED       bool
+	Timeout     time.Duration
+
+	// BaseURL and Client default to GoogleSearchURL and http.DefaultClient.
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewGoogle returns a web searcher capped at maxResults.
+func NewGoogle(client *http.Client, apiKey, cx string, maxResults int) *Google {
+	return &Google{
+		APIKey:     apiKey,
+		CX:         cx,
+		MaxResults: maxResults,
+		Timeout:    10 * time.Second,
+		Client:     client,
+	}
+}
+
+// NewTED returns a searcher that prefixes queries with "TED Talk" and marks every result as a TED talk.
+func NewTED(client *http.Client, apiKey, cx string, maxResults int) *Google {
+	g := NewGoogle(client, apiKey, cx, maxResults)
+	g.QueryPrefix = "TED Talk"
+	g.IsTED = true
+	return g
+}
+
+// Search pages through results until MaxResults items are collected or the API runs out.
+func (g *Google) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	if g.QueryPrefix != "" {
+		query = g.QueryPrefix + " " + query
+	}
+
+	var results []embedstore.Result
+	for start := 1; len(results) < g.MaxResults && start <= googleMaxStart; start += googlePageSize {
+		num := g.MaxResults - len(results)
+		if num > googlePageSize {
+			num = googlePageSize
+		}
+
+		items, err := g.page(ctx, query, start, num)
+		if err != nil {
+			return results, err
+		}
+		for _, item := range items {
+			item.IsTED = g.IsTED
+			results = append(results, item)
+		}
+		if len(items) < num {
+			break
+		}
+	}
+
+	if len(results) > g.MaxResults {
+		results = results[:g.MaxResults]
+	}
+	return results, nil
+}
+
+func (g *Google) page(ctx context.Context, query string, start, num int) ([]embedstore.Result, error) {
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		baseURL = GoogleSearchURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("key", g.APIKey)
+	q.Set("cx", g.CX)
+	q.Set("num", strconv.Itoa(num))
+	q.Set("start", strconv.Itoa(start))
+	u.RawQuery = q.Encode()
+
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	log.Printf("Google Search query: %q start=%d", query, start)
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to Google Search API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google search returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Items []embedstore.Result `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return response.Items, nil
+}
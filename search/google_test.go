@@ -0,0 +1,142 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// googleServer answers like Custom Search with total results in all, numbered by
+// position, and records the start and num of every request.
+type googleServer struct {
+	total int
+
+	mu     sync.Mutex
+	starts []int
+	nums   []int
+}
+
+func (s *googleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("key") != "key" || q.Get("cx") != "cx" {
+		http.Error(w, "bad credentials", http.StatusForbidden)
+		return
+	}
+	start, _ := strconv.Atoi(q.Get("start"))
+	num, _ := strconv.Atoi(q.Get("num"))
+	s.mu.Lock()
+	s.starts = append(s.starts, start)
+	s.nums = append(s.nums, num)
+	s.mu.Unlock()
+
+	type item struct {
+		Title string `json:"title"`
+		Link  string `json:"link"`
+	}
+	var items []item
+	for n := start; n < start+num && n <= s.total; n++ {
+		items = append(items, item{Title: fmt.Sprintf("Result %d", n), Link: fmt.Sprintf("https://example.com/%d", n)})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"items": items})
+}
+
+func TestGooglePagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int
+		maxResults int
+		wantStarts []int
+		wantNums   []int
+		wantCount  int
+	}{
+		{
+			name:       "one page",
+			total:      1000,
+			maxResults: 7,
+			wantStarts: []int{1},
+			wantNums:   []int{7},
+			wantCount:  7,
+		},
+		{
+			name:       "several pages",
+			total:      1000,
+			maxResults: 25,
+			wantStarts: []int{1, 11, 21},
+			wantNums:   []int{10, 10, 5},
+			wantCount:  25,
+		},
+		{
+			name:       "stops on a short page",
+			total:      14,
+			maxResults: 30,
+			wantStarts: []int{1, 11},
+			wantNums:   []int{10, 10},
+			wantCount:  14,
+		},
+		{
+			name:       "capped at start 91",
+			total:      1000,
+			maxResults: 150,
+			wantStarts: []int{1, 11, 21, 31, 41, 51, 61, 71, 81, 91},
+			wantNums:   []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10},
+			wantCount:  100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			google := &googleServer{total: tt.total}
+			srv := httptest.NewServer(google)
+			defer srv.Close()
+
+			g := NewTED(srv.Client(), "key", "cx", tt.maxResults)
+			g.BaseURL = srv.URL
+			results, err := g.Search(context.Background(), "creativity")
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+
+			if !reflect.DeepEqual(google.starts, tt.wantStarts) || !reflect.DeepEqual(google.nums, tt.wantNums) {
+				t.Errorf("requests start=%v num=%v, want start=%v num=%v", google.starts, google.nums, tt.wantStarts, tt.wantNums)
+			}
+			if len(results) != tt.wantCount {
+				t.Fatalf("got %d results, want %d", len(results), tt.wantCount)
+			}
+			for i, r := range results {
+				if want := fmt.Sprintf("https://example.com/%d", i+1); r.Link != want || !r.IsTED {
+					t.Errorf("result %d = %+v, want TED link %s", i, r, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGoogleErrorKeepsEarlierPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start") != "1" {
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		items := make([]map[string]string, 10)
+		for i := range items {
+			items[i] = map[string]string{"title": "Result", "link": fmt.Sprintf("https://example.com/%d", i)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"items": items})
+	}))
+	defer srv.Close()
+
+	g := NewGoogle(srv.Client(), "key", "cx", 20)
+	g.BaseURL = srv.URL
+	results, err := g.Search(context.Background(), "query")
+	if err == nil {
+		t.Fatal("Search succeeded despite a failing page")
+	}
+	if len(results) != 10 {
+		t.Errorf("got %d results from the first page, want 10", len(results))
+	}
+}
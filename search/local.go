@@ -0,0 +1,249 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"Audio-LLM-Contextual-Heygen/bm25"
+	"Audio-LLM-Contextual-Heygen/embedstore"
+	"Audio-LLM-Contextual-Heygen/extract"
+)
+
+// LocalScheme prefixes the links of documents served from a local corpus.
+const LocalScheme = "corpus://"
+
+type localDoc struct {
+	result  embedstore.Result
+	article *extract.Article
+}
+
+// Local searches an on-disk corpus with BM25 so the pipeline can run without network
+// access. The corpus is either a directory (walked recursively for .html, .htm, .md,
+// .txt, .json, .jsonl and .csv files) or a single .json/.jsonl/.csv file. JSON and CSV
+// files hold TED transcripts in the formats read by extract.ReadTEDTalks; JSONL lines
+// are either TED talks or {"title", "link", "text"} records.
+//
+// Local also implements Scrape, returning the indexed text, so it can stand in for the
+// web scraper when running offline.
+type Local struct {
+	MaxResults int
+
+	index *bm25.Index
+	docs  map[string]localDoc
+}
+
+// NewLocal indexes the corpus at path. Files in a corpus directory that fail to load
+// are logged and skipped; a corpus given as a single file must load.
+func NewLocal(path string, maxResults int) (*Local, error) {
+	l := &Local{
+		MaxResults: maxResults,
+		index:      bm25.New(),
+		docs:       make(map[string]localDoc),
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening corpus: %w", err)
+	}
+	if !info.IsDir() {
+		if err := l.loadFile(filepath.Dir(path), path); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// A directory may hold unrelated files such as package.json; one that does
+		// not parse as a corpus file is skipped rather than failing the whole corpus.
+		if err := l.loadFile(path, p); err != nil {
+			log.Printf("skipping corpus file %s: %v", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Len returns the number of indexed documents.
+func (l *Local) Len() int {
+	return len(l.docs)
+}
+
+func (l *Local) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	hits := l.index.Search(query, l.MaxResults)
+	results := make([]embedstore.Result, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, l.docs[hit.ID].result)
+	}
+	return results, nil
+}
+
+// Scrape returns the indexed text of a corpus document.
+func (l *Local) Scrape(ctx context.Context, result embedstore.Result) (*extract.Article, error) {
+	doc, ok := l.docs[result.Link]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the local corpus", result.Link)
+	}
+	return doc.article, nil
+}
+
+func (l *Local) add(link string, article *extract.Article, isTED bool) {
+	article.Text = strings.TrimSpace(article.Text)
+	if article.Text == "" {
+		return
+	}
+	if article.Title == "" {
+		article.Title = link
+	}
+	l.docs[link] = localDoc{
+		result:  embedstore.Result{Title: article.Title, Link: link, IsTED: isTED},
+		article: article,
+	}
+	l.index.Add(link, article.Title+"\n"+article.Text)
+}
+
+func (l *Local) loadFile(root, path string) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	link := LocalScheme + filepath.ToSlash(rel)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return l.loadHTML(link, path)
+	case ".md", ".markdown":
+		return l.loadMarkdown(link, path)
+	case ".txt":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		l.add(link, &extract.Article{Title: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Text: string(data)}, false)
+		return nil
+	case ".json", ".csv":
+		return l.loadTED(link, path)
+	case ".jsonl":
+		return l.loadJSONL(link, path)
+	}
+	return nil
+}
+
+func (l *Local) loadHTML(link, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	doc.Find("script, style, nav, footer").Remove()
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	text := strings.Join(strings.Fields(extract.ExtractContent(doc)), " ")
+	l.add(link, &extract.Article{Title: title, Text: text}, false)
+	return nil
+}
+
+func (l *Local) loadMarkdown(link, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			break
+		}
+	}
+	l.add(link, &extract.Article{Title: title, Text: string(data)}, false)
+	return nil
+}
+
+func (l *Local) loadTED(link, path string) error {
+	talks, err := extract.ReadTEDTalks(path)
+	if err != nil {
+		return err
+	}
+	for i, talk := range talks {
+		l.add(fmt.Sprintf("%s/%d", link, i), talk.Article(), true)
+	}
+	return nil
+}
+
+// corpusRecord covers both line formats accepted in JSONL corpora.
+type corpusRecord struct {
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	Text      string `json:"text"`
+	Speaker   string `json:"speaker"`
+	Output    string `json:"output"`
+	Subtitles string `json:"subtitles"`
+}
+
+func (l *Local) loadJSONL(link, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Documents are added only once the whole file has parsed, so a bad line leaves
+	// none of the file in the index.
+	type pending struct {
+		link    string
+		article *extract.Article
+		isTED   bool
+	}
+	var docs []pending
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for i := 0; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec corpusRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return fmt.Errorf("error unmarshalling %s line %d: %w", path, i+1, err)
+		}
+
+		docLink := rec.Link
+		if docLink == "" {
+			docLink = fmt.Sprintf("%s/%d", link, i)
+		}
+		if rec.Output != "" || rec.Subtitles != "" {
+			talk := extract.TEDTalk{Title: rec.Title, Speaker: rec.Speaker, Output: rec.Output, Subtitles: rec.Subtitles}
+			docs = append(docs, pending{docLink, talk.Article(), true})
+		} else {
+			docs = append(docs, pending{docLink, &extract.Article{Title: rec.Title, Text: rec.Text}, false})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	for _, doc := range docs {
+		l.add(doc.link, doc.article, doc.isTED)
+	}
+	return nil
+}
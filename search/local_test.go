@@ -0,0 +1,50 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLocalSkipsUnparsableFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"notes/whales.md":   "# Whales\n\nBlue whales are the largest animals ever known.",
+		"talks.json":        `[{"title": "Ocean life", "speaker": "Sylvia Earle", "output": "The ocean is the blue heart of the planet."}]`,
+		"package.json":      `{"name": "frontend", "version": "1.0.0"}`,
+		"broken.jsonl":      "{\"title\": \"ok\", \"text\": \"fine\"}\n{not json\n",
+		"talks.csv":         "\"unterminated,\n",
+		"docs/readme.txt":   "Plain text about coral reefs.",
+		"assets/logo.png":   "\x89PNG",
+		"empty/nothing.txt": "   ",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := NewLocal(dir, 5)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	if l.Len() != 3 {
+		t.Errorf("indexed %d documents, want 3", l.Len())
+	}
+	results, err := l.Search(context.Background(), "blue whales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Link != LocalScheme+"notes/whales.md" {
+		t.Fatalf("got %+v", results)
+	}
+
+	if _, err := NewLocal(filepath.Join(dir, "package.json"), 5); err == nil {
+		t.Error("a single unparsable corpus file was accepted")
+	}
+}
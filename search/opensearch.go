@@ -0,0 +1,158 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+var optionalParam = regexp.MustCompile(`\{[^{}]+\?\}`)
+
+// OpenSearch queries any endpoint described by an OpenSearch URL template that returns
+// JSON, such as a self-hosted SearxNG instance. The template may use {searchTerms},
+// {startPage}, {startIndex} and {count}; other optional parameters ({name?}) are left
+// empty. Results are read from either a SearxNG style "results" array (url, title) or a
+// Google style "items" array (link, title).
+type OpenSearch struct {
+	Template    string
+	QueryPrefix string
+	MaxResults  int
+	IsTED       bool
+	Timeout     time.Duration
+
+	// PageSize is the number of results the endpoint returns per page; it turns
+	// {startPage} into {startIndex} and is sent as {count}.
+	PageSize int
+	// MaxPages stops pagination against endpoints that never run dry.
+	MaxPages int
+
+	Client *http.Client
+}
+
+// NewSearxNG returns a searcher for the SearxNG instance at baseURL, capped at maxResults.
+func NewSearxNG(client *http.Client, baseURL string, maxResults int) *OpenSearch {
+	template := strings.TrimRight(baseURL, "/") + "/search?q={searchTerms}&format=json&pageno={startPage}"
+	return NewOpenSearch(client, template, maxResults)
+}
+
+// NewOpenSearch returns a searcher for an OpenSearch JSON URL template, capped at maxResults.
+func NewOpenSearch(client *http.Client, template string, maxResults int) *OpenSearch {
+	return &OpenSearch{
+		Template:   template,
+		MaxResults: maxResults,
+		Timeout:    10 * time.Second,
+		PageSize:   10,
+		MaxPages:   5,
+		Client:     client,
+	}
+}
+
+func (o *OpenSearch) Search(ctx context.Context, query string) ([]embedstore.Result, error) {
+	if o.QueryPrefix != "" {
+		query = o.QueryPrefix + " " + query
+	}
+	paged := strings.Contains(o.Template, "{startPage") || strings.Contains(o.Template, "{startIndex")
+	maxPages := o.MaxPages
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+
+	seen := make(map[string]struct{})
+	var results []embedstore.Result
+	for page := 1; len(results) < o.MaxResults && page <= maxPages; page++ {
+		items, err := o.page(ctx, query, page)
+		if err != nil {
+			return results, err
+		}
+		for _, item := range items {
+			if _, dup := seen[item.Link]; dup || item.Link == "" {
+				continue
+			}
+			seen[item.Link] = struct{}{}
+			item.IsTED = o.IsTED
+			results = append(results, item)
+		}
+		if !paged || len(items) == 0 {
+			break
+		}
+	}
+
+	if len(results) > o.MaxResults {
+		results = results[:o.MaxResults]
+	}
+	return results, nil
+}
+
+func (o *OpenSearch) expand(query string, page int) string {
+	pageSize := o.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	startIndex := strconv.Itoa((page-1)*pageSize + 1)
+	r := strings.NewReplacer(
+		"{searchTerms}", url.QueryEscape(query),
+		"{startPage}", strconv.Itoa(page),
+		"{startPage?}", strconv.Itoa(page),
+		"{startIndex}", startIndex,
+		"{startIndex?}", startIndex,
+		"{count}", strconv.Itoa(pageSize),
+		"{count?}", strconv.Itoa(pageSize),
+	)
+	return optionalParam.ReplaceAllString(r.Replace(o.Template), "")
+}
+
+func (o *OpenSearch) page(ctx context.Context, query string, page int) ([]embedstore.Result, error) {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.expand(query, page), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	log.Printf("OpenSearch query: %q page=%d", query, page)
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to OpenSearch endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensearch endpoint returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Results []struct {
+			Title string `json:"title"`
+			URL   string `json:"url"`
+		} `json:"results"`
+		Items []embedstore.Result `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	items := response.Items
+	for _, r := range response.Results {
+		items = append(items, embedstore.Result{Title: r.Title, Link: r.URL})
+	}
+	return items, nil
+}
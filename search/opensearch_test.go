@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// searxServer answers like SearxNG with pageSize results per page, numbered across
+// pages, and records the queries it was sent.
+type searxServer struct {
+	pageSize, pages int
+
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func (s *searxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/search" || r.Header.Get("Accept") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.queries = append(s.queries, r.URL.Query())
+	s.mu.Unlock()
+
+	var page int
+	fmt.Sscan(r.URL.Query().Get("pageno"), &page)
+	type result struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+	var results []result
+	if page <= s.pages {
+		for i := 0; i < s.pageSize; i++ {
+			n := (page-1)*s.pageSize + i
+			results = append(results, result{Title: fmt.Sprintf("Result %d", n), URL: fmt.Sprintf("https://example.com/%d", n)})
+		}
+		// A repeat of the first result, as SearxNG sometimes returns across pages.
+		results = append(results, result{Title: "Result 0", URL: "https://example.com/0"}, result{Title: "No link"})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"query": r.URL.Query().Get("q"), "results": results})
+}
+
+func TestSearxNGQueryAndMapping(t *testing.T) {
+	searx := &searxServer{pageSize: 3, pages: 2}
+	srv := httptest.NewServer(searx)
+	defer srv.Close()
+
+	s := NewSearxNG(srv.Client(), srv.URL+"/", 5)
+	s.QueryPrefix = "site:ted.com"
+	s.IsTED = true
+	results, err := s.Search(context.Background(), "black holes & stars")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if len(searx.queries) != 2 {
+		t.Fatalf("got %d requests, want 2", len(searx.queries))
+	}
+	for i, q := range searx.queries {
+		if got := q.Get("q"); got != "site:ted.com black holes & stars" {
+			t.Errorf("request %d q = %q", i, got)
+		}
+		if got := q.Get("format"); got != "json" {
+			t.Errorf("request %d format = %q", i, got)
+		}
+		if got, want := q.Get("pageno"), fmt.Sprint(i+1); got != want {
+			t.Errorf("request %d pageno = %q, want %q", i, got, want)
+		}
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5: %+v", len(results), results)
+	}
+	for i, r := range results {
+		if want := fmt.Sprintf("https://example.com/%d", i); r.Link != want || r.Title != fmt.Sprintf("Result %d", i) {
+			t.Errorf("result %d = %+v, want link %s", i, r, want)
+		}
+		if !r.IsTED {
+			t.Errorf("result %d not marked as TED", i)
+		}
+	}
+}
+
+func TestOpenSearchTemplateAndItems(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte(`{"items": [{"title": "Go", "link": "https://go.dev"}, {"title": "Empty", "link": ""}]}`))
+	}))
+	defer srv.Close()
+
+	s := NewOpenSearch(srv.Client(), srv.URL+"/api?q={searchTerms}&start={startIndex}&num={count}&lang={language?}", 10)
+	s.PageSize = 20
+	s.MaxPages = 1
+	results, err := s.Search(context.Background(), "golang")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got.Get("q") != "golang" || got.Get("start") != "1" || got.Get("num") != "20" || got.Get("lang") != "" {
+		t.Errorf("unexpected query %v", got)
+	}
+	if len(results) != 1 || results[0].Link != "https://go.dev" || results[0].Title != "Go" {
+		t.Errorf("got %+v", results)
+	}
+}
+
+func TestOpenSearchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		timeout time.Duration
+		wantErr string
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "boom", http.StatusInternalServerError)
+			},
+			wantErr: "opensearch endpoint returned status 500",
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr: "opensearch endpoint returned status 429",
+		},
+		{
+			name: "invalid json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<html>captcha</html>`))
+			},
+			wantErr: "error decoding response",
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+			timeout: 50 * time.Millisecond,
+			wantErr: "context deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			s := NewSearxNG(srv.Client(), srv.URL, 10)
+			if tt.timeout > 0 {
+				s.Timeout = tt.timeout
+			}
+			start := time.Now()
+			results, err := s.Search(context.Background(), "query")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Search error = %v, want %q", err, tt.wantErr)
+			}
+			if len(results) != 0 {
+				t.Errorf("got results %+v on error", results)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Search took %v", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package search
+
+import (
+	"context"
+
+	"Audio-LLM-Contextual-Heygen/embedstore"
+)
+
+// Searcher finds candidate pages for a query.
+type Searcher interface {
+	Search(ctx context.Context, query string) ([]embedstore.Result, error)
+}
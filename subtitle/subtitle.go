@@ -0,0 +1,175 @@
+package subtitle
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is one timed piece of a subtitle track or transcript.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+var (
+	tagPattern    = regexp.MustCompile(`<[^>]*>`)
+	vttBlockTypes = []string{"NOTE", "STYLE", "REGION"}
+)
+
+// Parse detects whether data is WebVTT or SRT and parses it.
+func Parse(data string) ([]Cue, error) {
+	data = normalize(data)
+	if strings.HasPrefix(data, "WEBVTT") {
+		return ParseVTT(data)
+	}
+	return ParseSRT(data)
+}
+
+// ParseSRT parses an SRT document. Formatting tags are stripped and multi-line cue
+// text is joined with spaces. Blocks without a valid timing line are skipped; an
+// error is returned only when nothing could be parsed from non-empty input.
+func ParseSRT(data string) ([]Cue, error) {
+	return parseBlocks(normalize(data), "srt")
+}
+
+// ParseVTT parses a WebVTT document. NOTE, STYLE and REGION blocks, cue identifiers,
+// cue settings and voice/class/timestamp tags are ignored.
+func ParseVTT(data string) ([]Cue, error) {
+	data = normalize(data)
+	if !strings.HasPrefix(data, "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+	return parseBlocks(data, "vtt")
+}
+
+func normalize(data string) string {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	return strings.ReplaceAll(data, "\r", "\n")
+}
+
+func parseBlocks(data, format string) ([]Cue, error) {
+	var cues []Cue
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 0 || skipBlock(lines[0], format) {
+			continue
+		}
+		for i, line := range lines {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+			start, end, err := parseTiming(line)
+			if err != nil {
+				break
+			}
+			text := cleanCueText(strings.Join(lines[i+1:], " "))
+			if text != "" {
+				cues = append(cues, Cue{Start: start, End: end, Text: text})
+			}
+			break
+		}
+	}
+	if len(cues) == 0 && strings.TrimSpace(data) != "" && strings.TrimSpace(data) != "WEBVTT" {
+		return nil, fmt.Errorf("no %s cues found", format)
+	}
+	return cues, nil
+}
+
+func skipBlock(first, format string) bool {
+	if format != "vtt" {
+		return false
+	}
+	if strings.HasPrefix(first, "WEBVTT") {
+		return true
+	}
+	for _, t := range vttBlockTypes {
+		if first == t || strings.HasPrefix(first, t+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanCueText(text string) string {
+	text = tagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func parseTiming(line string) (start, end time.Duration, err error) {
+	from, to, _ := strings.Cut(line, "-->")
+	// Anything after the end timestamp is cue settings.
+	fields := strings.Fields(to)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("missing end timestamp in %q", line)
+	}
+	if start, err = ParseTimestamp(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	if end, err = ParseTimestamp(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// ParseTimestamp parses "HH:MM:SS,mmm" (SRT) or "[HH:]MM:SS.mmm" (WebVTT).
+func ParseTimestamp(ts string) (time.Duration, error) {
+	parts := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+
+	var h, m int
+	var err error
+	if len(parts) == 3 {
+		if h, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		parts = parts[1:]
+	}
+	if m, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	s, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	// Both formats stop at milliseconds; rounding keeps "01.001" from landing a
+	// nanosecond short through float error.
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(math.Round(s*1000))*time.Millisecond, nil
+}
+
+// Group merges consecutive cues into chunks that each span at most window, measured
+// from the start of the chunk's first cue to the end of its last. A cue longer than
+// window becomes a chunk of its own.
+func Group(cues []Cue, window time.Duration) []Cue {
+	var chunks []Cue
+	for _, cue := range cues {
+		n := len(chunks)
+		if n > 0 && cue.End-chunks[n-1].Start <= window {
+			chunks[n-1].Text += " " + cue.Text
+			if cue.End > chunks[n-1].End {
+				chunks[n-1].End = cue.End
+			}
+			continue
+		}
+		chunks = append(chunks, cue)
+	}
+	return chunks
+}
+
+// Text joins the text of every cue, for consumers that only need the transcript.
+func Text(cues []Cue) string {
+	parts := make([]string, len(cues))
+	for i, cue := range cues {
+		parts[i] = cue.Text
+	}
+	return strings.Join(parts, " ")
+}
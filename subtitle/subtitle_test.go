@@ -0,0 +1,196 @@
+package subtitle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "00:00:01,234", want: ms(1234)},
+		{in: "00:00:01.234", want: ms(1234)},
+		{in: "01:02:03,004", want: time.Hour + 2*time.Minute + 3*time.Second + ms(4)},
+		{in: "02:03.250", want: 2*time.Minute + 3*time.Second + ms(250)},
+		{in: "10:00:00.999", want: 10*time.Hour + ms(999)},
+		{in: "00:00:07", want: 7 * time.Second},
+		{in: "00:00:01.001", want: ms(1001)},
+		{in: "00:00:58,003", want: ms(58003)},
+		{in: "12.5", wantErr: true},
+		{in: "1:2:3:4", wantErr: true},
+		{in: "aa:00:01.000", wantErr: true},
+		{in: "00:bb:01.000", wantErr: true},
+		{in: "00:00:cc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTimestamp(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, in string
+		want     []Cue
+		wantErr  bool
+	}{
+		{
+			name: "srt",
+			in: "1\n00:00:01,000 --> 00:00:02,500\nHello <i>there</i>,\nworld.\n\n" +
+				"2\n00:00:03,000 --> 00:00:04,000\nTom &amp; Jerry\n",
+			want: []Cue{{ms(1000), ms(2500), "Hello there, world."}, {ms(3000), ms(4000), "Tom & Jerry"}},
+		},
+		{
+			name: "srt with crlf and bom",
+			in:   "\ufeff1\r\n00:00:01,000 --> 00:00:02,000\r\nFirst\r\nline\r\n\r\n2\r\n00:00:02,000 --> 00:00:03,000\r\nSecond\r\n",
+			want: []Cue{{ms(1000), ms(2000), "First line"}, {ms(2000), ms(3000), "Second"}},
+		},
+		{
+			name: "srt with a malformed cue",
+			in: "1\n00:00:01,000 --> 00:00:02,000\nKept\n\n" +
+				"2\n00:00:xx,000 --> 00:00:03,000\nDropped\n\n" +
+				"3\nno timing here\n\n" +
+				"4\n00:00:04,000 --> 00:00:05,000\nAlso kept\n",
+			want: []Cue{{ms(1000), ms(2000), "Kept"}, {ms(4000), ms(5000), "Also kept"}},
+		},
+		{
+			name: "vtt with notes, style and settings",
+			in: "WEBVTT - a talk\n\n" +
+				"NOTE this is a comment\nspanning --> two lines\n\n" +
+				"STYLE\n::cue { color: yellow }\n\n" +
+				"intro\n00:01.000 --> 00:02.500 align:start position:10%\n<v Speaker>Hello</v> <c.loud>everyone</c>\n\n" +
+				"01:00:00.000 --> 01:00:01.250 line:0\nAn hour in\n",
+			want: []Cue{{ms(1000), ms(2500), "Hello everyone"}, {time.Hour, time.Hour + ms(1250), "An hour in"}},
+		},
+		{
+			name: "vtt with crlf",
+			in:   "WEBVTT\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nOne\r\n\r\n00:02.000 --> 00:03.000\r\nTwo\r\n",
+			want: []Cue{{ms(1000), ms(2000), "One"}, {ms(2000), ms(3000), "Two"}},
+		},
+		{
+			name: "vtt header only",
+			in:   "WEBVTT\n\n",
+		},
+		{
+			name: "empty",
+			in:   "",
+		},
+		{
+			name:    "no cues",
+			in:      "just some text\n\nwithout timings",
+			wantErr: true,
+		},
+		{
+			name:    "vtt without cues",
+			in:      "WEBVTT\n\nNOTE nothing else\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVTTRequiresHeader(t *testing.T) {
+	if _, err := ParseVTT("00:01.000 --> 00:02.000\nHello\n"); err == nil || !strings.Contains(err.Error(), "WEBVTT") {
+		t.Errorf("ParseVTT without header error = %v", err)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	cues := []Cue{
+		{0, ms(2000), "a"},
+		{ms(2000), ms(4000), "b"},
+		{ms(4000), ms(5000), "c"},
+		{ms(5000), ms(17000), "long"},
+		{ms(17000), ms(18000), "d"},
+	}
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   []Cue
+	}{
+		{
+			// "c" ends exactly at the window and still joins; "long" alone exceeds it.
+			name:   "boundary inclusive",
+			window: 5 * time.Second,
+			want: []Cue{
+				{0, ms(5000), "a b c"},
+				{ms(5000), ms(17000), "long"},
+				{ms(17000), ms(18000), "d"},
+			},
+		},
+		{
+			name:   "just under the boundary",
+			window: ms(4999),
+			want: []Cue{
+				{0, ms(4000), "a b"},
+				{ms(4000), ms(5000), "c"},
+				{ms(5000), ms(17000), "long"},
+				{ms(17000), ms(18000), "d"},
+			},
+		},
+		{
+			name:   "everything fits",
+			window: time.Minute,
+			want:   []Cue{{0, ms(18000), "a b c long d"}},
+		},
+		{
+			name:   "zero window",
+			window: 0,
+			want:   cues,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Group(cues, tt.window); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Group = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Group(nil, time.Minute); len(got) != 0 {
+		t.Errorf("Group(nil) = %+v", got)
+	}
+	// Overlapping cues keep the latest end.
+	overlap := []Cue{{0, ms(3000), "x"}, {ms(1000), ms(2000), "y"}}
+	if got := Group(overlap, time.Minute); len(got) != 1 || got[0].End != ms(3000) || got[0].Text != "x y" {
+		t.Errorf("Group(overlap) = %+v", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	if got := Text([]Cue{{Text: "one"}, {Text: "two"}}); got != "one two" {
+		t.Errorf("Text = %q", got)
+	}
+}
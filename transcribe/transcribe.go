@@ -0,0 +1,99 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+// SpeechToText transcribes an audio file (16 kHz mono WAV) into timestamped cues.
+type SpeechToText interface {
+	Transcribe(ctx context.Context, audioPath string) ([]subtitle.Cue, error)
+}
+
+// ErrNoSubtitles is returned by ExtractSubtitles when the media has no subtitle stream.
+var ErrNoSubtitles = errors.New("no subtitle stream")
+
+// Transcriber turns an uploaded video or audio file into transcript cues. Embedded
+// subtitle streams are used when present since they are free and exact; otherwise the
+// audio track is extracted with ffmpeg and handed to STT.
+type Transcriber struct {
+	// FFmpeg is the ffmpeg binary; empty means "ffmpeg" from PATH.
+	FFmpeg string
+	STT    SpeechToText
+}
+
+func (t *Transcriber) Transcribe(ctx context.Context, mediaPath string) ([]subtitle.Cue, error) {
+	srt, err := t.ExtractSubtitles(ctx, mediaPath)
+	if err == nil {
+		cues, err := subtitle.ParseSRT(srt)
+		if err == nil && len(cues) > 0 {
+			log.Printf("using embedded subtitles for %s", filepath.Base(mediaPath))
+			return cues, nil
+		}
+	} else if !errors.Is(err, ErrNoSubtitles) {
+		return nil, err
+	}
+
+	if t.STT == nil {
+		return nil, errors.New("media has no subtitles and no speech-to-text backend is configured")
+	}
+
+	audio, err := os.CreateTemp("", "audio-*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	audio.Close()
+	defer os.Remove(audio.Name())
+
+	if err := t.ExtractAudio(ctx, mediaPath, audio.Name()); err != nil {
+		return nil, err
+	}
+	return t.STT.Transcribe(ctx, audio.Name())
+}
+
+// ExtractAudio writes the first audio track of mediaPath to outPath as 16 kHz mono WAV.
+func (t *Transcriber) ExtractAudio(ctx context.Context, mediaPath, outPath string) error {
+	_, err := t.ffmpeg(ctx, "-y", "-i", mediaPath, "-vn", "-map", "0:a:0", "-ac", "1", "-ar", "16000", "-f", "wav", outPath)
+	if err != nil {
+		return fmt.Errorf("failed to extract audio: %w", err)
+	}
+	return nil
+}
+
+// ExtractSubtitles returns the first subtitle stream of mediaPath as SRT text.
+func (t *Transcriber) ExtractSubtitles(ctx context.Context, mediaPath string) (string, error) {
+	out, err := t.ffmpeg(ctx, "-i", mediaPath, "-map", "0:s:0", "-f", "srt", "-")
+	if err != nil {
+		if strings.Contains(err.Error(), "matches no streams") {
+			return "", ErrNoSubtitles
+		}
+		return "", fmt.Errorf("failed to extract subtitles: %w", err)
+	}
+	return string(out), nil
+}
+
+func (t *Transcriber) ffmpeg(ctx context.Context, args ...string) ([]byte, error) {
+	bin := t.FFmpeg
+	if bin == "" {
+		bin = "ffmpeg"
+	}
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,110 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+// fakeFFmpeg writes an ffmpeg stand-in that prints subs for subtitle extraction, or
+// reports no subtitle stream when subs is empty, and writes a dummy WAV for audio
+// extraction.
+func fakeFFmpeg(t *testing.T, subs string) string {
+	dir := t.TempDir()
+	srt := filepath.Join(dir, "subs.srt")
+	if err := os.WriteFile(srt, []byte(subs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return writeScript(t, dir, "ffmpeg", `case "$*" in
+*"0:s:0"*)
+	if [ ! -s `+srt+` ]; then
+		echo "Stream map '0:s:0' matches no streams." >&2
+		exit 1
+	fi
+	cat `+srt+`
+	;;
+*)
+	for last; do :; done
+	printf 'RIFF' > "$last"
+	;;
+esac
+`)
+}
+
+// fakeSTT is a SpeechToText that returns the same cues for every file and records
+// the audio paths it was given.
+type fakeSTT struct {
+	Cues []subtitle.Cue
+	// Err, when set, is returned instead of the cues.
+	Err   error
+	Calls []string
+}
+
+func (s *fakeSTT) Transcribe(ctx context.Context, audioPath string) ([]subtitle.Cue, error) {
+	s.Calls = append(s.Calls, audioPath)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return append([]subtitle.Cue(nil), s.Cues...), nil
+}
+
+func TestTranscriberUsesEmbeddedSubtitles(t *testing.T) {
+	stt := &fakeSTT{Cues: []subtitle.Cue{{Text: "from speech"}}}
+	tr := &Transcriber{FFmpeg: fakeFFmpeg(t, "1\n00:00:01,000 --> 00:00:02,000\nHello there.\n"), STT: stt}
+
+	cues, err := tr.Transcribe(context.Background(), "talk.mkv")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(cues) != 1 || cues[0].Text != "Hello there." || cues[0].Start != time.Second {
+		t.Errorf("got %+v", cues)
+	}
+	if len(stt.Calls) != 0 {
+		t.Errorf("speech-to-text called despite subtitles")
+	}
+}
+
+func TestTranscriberFallsBackToSpeech(t *testing.T) {
+	stt := &fakeSTT{Cues: []subtitle.Cue{{Start: 0, End: 3 * time.Second, Text: "Transcribed speech."}}}
+	tr := &Transcriber{FFmpeg: fakeFFmpeg(t, ""), STT: stt}
+
+	cues, err := tr.Transcribe(context.Background(), "meeting.mp4")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(cues) != 1 || cues[0].Text != "Transcribed speech." {
+		t.Errorf("got %+v", cues)
+	}
+	if len(stt.Calls) != 1 || !strings.HasSuffix(stt.Calls[0], ".wav") {
+		t.Fatalf("speech-to-text calls = %q", stt.Calls)
+	}
+	if _, err := os.Stat(stt.Calls[0]); !os.IsNotExist(err) {
+		t.Errorf("extracted audio %s was not removed", stt.Calls[0])
+	}
+}
+
+func TestTranscriberErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stt     SpeechToText
+		wantErr string
+	}{
+		{"no speech-to-text", nil, "no speech-to-text backend"},
+		{"speech-to-text failure", &fakeSTT{Err: errors.New("model crashed")}, "model crashed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transcriber{FFmpeg: fakeFFmpeg(t, ""), STT: tt.stt}
+			_, err := tr.Transcribe(context.Background(), "meeting.mp4")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+// WhisperCPP transcribes locally with the whisper.cpp command line tool, so uploads can
+// be transcribed without sending audio to a cloud service.
+type WhisperCPP struct {
+	// Binary is the whisper.cpp executable, e.g. "whisper-cli".
+	Binary string
+	// Model is the path to a ggml model file.
+	Model string
+	// Language is passed to -l; empty means "auto".
+	Language string
+}
+
+func (w *WhisperCPP) Transcribe(ctx context.Context, audioPath string) ([]subtitle.Cue, error) {
+	out, err := os.MkdirTemp("", "whisper-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(out)
+
+	language := w.Language
+	if language == "" {
+		language = "auto"
+	}
+	prefix := out + "/transcript"
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, w.Binary, "-m", w.Model, "-l", language, "-f", audioPath, "-osrt", "-of", prefix, "-np")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("whisper command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	srt, err := os.ReadFile(prefix + ".srt")
+	if err != nil {
+		return nil, fmt.Errorf("error reading whisper output: %w", err)
+	}
+	return parseWhisperSRT(string(srt))
+}
+
+// nonSpeech matches the annotations whisper writes for silence and noise, such as
+// "[BLANK_AUDIO]", "[Music]" or "(applause)".
+var nonSpeech = regexp.MustCompile(`^(\[[^\]]*\]|\([^)]*\)|\*[^*]*\*)$`)
+
+// parseWhisperSRT parses whisper's SRT output, dropping cues that only annotate
+// silence or noise so they do not end up in transcript chunks.
+func parseWhisperSRT(srt string) ([]subtitle.Cue, error) {
+	cues, err := subtitle.ParseSRT(srt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing whisper output: %w", err)
+	}
+	speech := cues[:0]
+	for _, cue := range cues {
+		if !nonSpeech.MatchString(cue.Text) {
+			speech = append(speech, cue)
+		}
+	}
+	return speech, nil
+}
@@ -0,0 +1,122 @@
+package transcribe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"Audio-LLM-Contextual-Heygen/subtitle"
+)
+
+// whisperOutput is what whisper.cpp writes with -osrt: numbered cues, text with a
+// leading space and bracketed annotations for silence.
+const whisperOutput = `1
+00:00:00,000 --> 00:00:04,200
+ Welcome to the quarterly review.
+
+2
+00:00:04,200 --> 00:00:07,000
+ [BLANK_AUDIO]
+
+3
+00:00:07,000 --> 00:00:09,500
+ (applause)
+
+4
+00:00:09,500 --> 00:01:02,040
+ Revenue grew by twelve percent,
+ mostly in Europe.
+`
+
+func TestParseWhisperSRT(t *testing.T) {
+	tests := []struct {
+		name    string
+		srt     string
+		want    []subtitle.Cue
+		wantErr bool
+	}{
+		{
+			name: "speech only",
+			srt:  whisperOutput,
+			want: []subtitle.Cue{
+				{Start: 0, End: 4200 * time.Millisecond, Text: "Welcome to the quarterly review."},
+				{Start: 9500 * time.Millisecond, End: time.Minute + 2040*time.Millisecond, Text: "Revenue grew by twelve percent, mostly in Europe."},
+			},
+		},
+		{name: "silence", srt: "1\r\n00:00:00,000 --> 00:00:30,000\r\n [BLANK_AUDIO]\r\n", want: []subtitle.Cue{}},
+		{name: "empty file", srt: "", want: nil},
+		{name: "not srt", srt: "whisper_init_from_file: failed to load model", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWhisperSRT(tt.srt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d cues %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("cue %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// writeScript writes an executable shell script into dir and returns its path.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWhisperCPPTranscribe(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output.srt")
+	if err := os.WriteFile(output, []byte(whisperOutput), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := filepath.Join(dir, "args")
+	// The fake copies the canned output to the -of prefix like whisper-cli does.
+	bin := writeScript(t, dir, "whisper-cli", `echo "$@" > `+args+`
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-of" ]; then out="$2"; fi
+	shift
+done
+cp `+output+` "$out.srt"
+`)
+
+	w := &WhisperCPP{Binary: bin, Model: "ggml-base.bin"}
+	cues, err := w.Transcribe(context.Background(), "/tmp/audio.wav")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(cues) != 2 || cues[1].Text != "Revenue grew by twelve percent, mostly in Europe." {
+		t.Errorf("got %+v", cues)
+	}
+	got, _ := os.ReadFile(args)
+	if want := "-m ggml-base.bin -l auto -f /tmp/audio.wav -osrt"; !strings.Contains(string(got), want) {
+		t.Errorf("whisper called with %q, want %q", got, want)
+	}
+}
+
+func TestWhisperCPPFailure(t *testing.T) {
+	bin := writeScript(t, t.TempDir(), "whisper-cli", "echo 'failed to load model' >&2\nexit 2\n")
+	w := &WhisperCPP{Binary: bin, Model: "missing.bin", Language: "de"}
+	_, err := w.Transcribe(context.Background(), "/tmp/audio.wav")
+	if err == nil || !strings.Contains(err.Error(), "failed to load model") {
+		t.Errorf("error = %v, want whisper's stderr", err)
+	}
+}